@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/taasan/postgang/ical"
+)
+
+// parseAlarm parses an alarm trigger relative to the start of the
+// delivery day: an RFC 5545 duration like -PT12H, or a time of day
+// like 07:30.
+func parseAlarm(value string) (time.Duration, error) {
+	if !strings.Contains(value, ":") {
+		return ical.ParseDuration(value)
+	}
+	return parseClock(value)
+}
+
+// alarmFlag is a flag.Value adding alarms from repeated flags to a
+// list shared by -alarm and -alarm-at.
+type alarmFlag struct {
+	alarms *[]string
+	// clock is set for -alarm-at, taking a time of day.
+	clock bool
+}
+
+func (f alarmFlag) String() string {
+	if f.alarms == nil {
+		return ""
+	}
+	return strings.Join(*f.alarms, ",")
+}
+
+func (f alarmFlag) Set(value string) error {
+	if f.clock != strings.Contains(value, ":") {
+		if f.clock {
+			return fmt.Errorf("invalid time %q, expected HH:MM", value)
+		}
+		return fmt.Errorf("invalid alarm %q, expected a duration like -PT12H", value)
+	}
+	if _, err := parseAlarm(value); err != nil {
+		return err
+	}
+	*f.alarms = append(*f.alarms, value)
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAlarm(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"07:30":  7*time.Hour + 30*time.Minute,
+		"00:00":  0,
+		"-PT12H": -12 * time.Hour,
+		"PT6H":   6 * time.Hour,
+	} {
+		got, err := parseAlarm(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("%s: %s != %s", value, got, expected)
+		}
+	}
+	for _, value := range []string{"24:00", "7:3", "12H", ""} {
+		if _, err := parseAlarm(value); err == nil {
+			t.Fatalf("%q: expected error", value)
+		}
+	}
+}
+
+func TestParseArgsAlarms(t *testing.T) {
+	got, err := parseArgs(commandLine(), []string{"--code=6666", "--alarm", "-PT5H", "--alarm-at", "07:30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Duration{-5 * time.Hour, 7*time.Hour + 30*time.Minute}
+	if len(got.jobs[0].alarms) != 2 || got.jobs[0].alarms[0] != expected[0] || got.jobs[0].alarms[1] != expected[1] {
+		t.Fatalf("%v != %v", got.jobs[0].alarms, expected)
+	}
+	for _, args := range [][]string{{"--alarm", "07:30"}, {"--alarm-at", "-PT5H"}} {
+		if _, err = parseArgs(commandLine(), append([]string{"--code=6666"}, args...)); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestCalendarAlarms(t *testing.T) {
+	cal := calendarTFixture()
+	cal.alarms = []time.Duration{7*time.Hour + 30*time.Minute}
+	got := toVCalendar(cal).String()
+	expected := "BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:PT7H30M\r\nDESCRIPTION:Tøm postkassen\r\nEND:VALARM\r\nEND:VEVENT\r\n"
+	if n := strings.Count(got, expected); n != len(cal.dates) {
+		t.Fatalf("Expected %d alarms, got %d in\n%s", len(cal.dates), n, got)
+	}
+}
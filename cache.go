@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// cachedHeaders are the response headers kept in the cache.
+var cachedHeaders = []string{"Content-Type", "Date", "ETag", "Last-Modified"}
+
+type cacheEntry struct {
+	URL    string      `json:"url"`
+	Stored time.Time   `json:"stored"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// cacheTransport is a http.RoundTripper keeping successful GET
+// responses in dir. Cached responses younger than maxAge are used
+// without asking the server, older ones are revalidated with
+// conditional requests.
+type cacheTransport struct {
+	dir       string
+	maxAge    time.Duration
+	transport http.RoundTripper
+	now       func() time.Time
+}
+
+func newCacheTransport(dir string, maxAge time.Duration, transport http.RoundTripper) *cacheTransport {
+	return &cacheTransport{
+		dir:       dir,
+		maxAge:    maxAge,
+		transport: transport,
+		now:       time.Now,
+	}
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (c *cacheTransport) path(req *http.Request) string {
+	return filepath.Join(c.dir, requestFileName(req))
+}
+
+// requestFileName returns a file name for the request URL, without
+// credentials and fragment.
+func requestFileName(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	u.Fragment = ""
+	return unsafeFileNameChars.ReplaceAllString(u.Host+u.EscapedPath()+"?"+u.RawQuery, "_") + ".json"
+}
+
+func (c *cacheTransport) load(req *http.Request) *cacheEntry {
+	bs, err := os.ReadFile(c.path(req))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Print(err)
+		}
+		return nil
+	}
+	var entry cacheEntry
+	if err = json.Unmarshal(bs, &entry); err != nil {
+		log.Printf("Ignoring broken cache entry: %s", err)
+		return nil
+	}
+	if entry.URL != req.URL.String() {
+		return nil
+	}
+	return &entry
+}
+
+func (c *cacheTransport) store(req *http.Request, entry *cacheEntry) error {
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path(req), bs)
+}
+
+// writeFileAtomic writes bs to a temporary file next to path, then
+// renames it, creating the directory when missing.
+func writeFileAtomic(path string, bs []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(dir, ".postgang-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err = tmpFile.Write(bs); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+func (entry *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK)),
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+func (c *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return c.transport.RoundTrip(req)
+	}
+	entry := c.load(req)
+	if entry != nil && c.now().Sub(entry.Stored) < c.maxAge {
+		log.Printf("Using cached response for %s", req.URL)
+		return entry.response(req), nil
+	}
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry != nil:
+		resp.Body.Close()
+		log.Printf("Not modified, using cached response for %s", req.URL)
+		if date := resp.Header.Get("Date"); date != "" {
+			entry.Header.Set("Date", date)
+		}
+		entry.Stored = c.now()
+	case resp.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		entry = &cacheEntry{URL: req.URL.String(), Stored: c.now(), Header: http.Header{}, Body: body}
+		for _, name := range cachedHeaders {
+			if value := resp.Header.Get(name); value != "" {
+				entry.Header.Set(name, value)
+			}
+		}
+		c.storeOrLog(req, entry)
+		return resp, nil
+	default:
+		return resp, nil
+	}
+	c.storeOrLog(req, entry)
+	return entry.response(req), nil
+}
+
+func (c *cacheTransport) storeOrLog(req *http.Request, entry *cacheEntry) {
+	if err := c.store(req, entry); err != nil {
+		log.Printf("Unable to cache response: %s", err)
+	}
+}
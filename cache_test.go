@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testETag = `"v1"`
+
+type cacheBackend struct {
+	requests    int
+	conditional int
+}
+
+func (b *cacheBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.requests++
+	w.Header().Set("ETag", testETag)
+	w.Header().Set("Last-Modified", "Tue, 28 Dec 2021 00:00:00 GMT")
+	if r.Header.Get("If-None-Match") == testETag {
+		b.conditional++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, `{"delivery_dates":["2021-12-28"]}`)
+}
+
+func getBody(t *testing.T, client *http.Client, u string) string {
+	resp, err := client.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bs)
+}
+
+func TestCacheRevalidates(t *testing.T) {
+	backend := &cacheBackend{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	client := &http.Client{Transport: newCacheTransport(t.TempDir(), 0, http.DefaultTransport)}
+	first := getBody(t, client, server.URL+"/6666")
+	second := getBody(t, client, server.URL+"/6666")
+	if first != second {
+		t.Fatalf("%s != %s", first, second)
+	}
+	if backend.requests != 2 || backend.conditional != 1 {
+		t.Fatalf("Expected 2 requests, 1 conditional, got %d, %d", backend.requests, backend.conditional)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	backend := &cacheBackend{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	transport := newCacheTransport(t.TempDir(), time.Hour, http.DefaultTransport)
+	clock := time.Now()
+	transport.now = func() time.Time { return clock }
+	client := &http.Client{Transport: transport}
+	getBody(t, client, server.URL+"/6666")
+	getBody(t, client, server.URL+"/6666")
+	if backend.requests != 1 {
+		t.Fatalf("Expected 1 request, got %d", backend.requests)
+	}
+	getBody(t, client, server.URL+"/1234")
+	if backend.requests != 2 {
+		t.Fatalf("Expected 2 requests, got %d", backend.requests)
+	}
+	clock = clock.Add(2 * time.Hour)
+	getBody(t, client, server.URL+"/6666")
+	if backend.requests != 3 || backend.conditional != 1 {
+		t.Fatalf("Expected 3 requests, 1 conditional, got %d, %d", backend.requests, backend.conditional)
+	}
+}
+
+func TestCacheIgnoresErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	transport := newCacheTransport(t.TempDir(), time.Hour, http.DefaultTransport)
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/taasan/postgang/ical"
+)
+
+const (
+	caldavPasswordEnv        = "POSTGANG_CALDAV_PASSWORD"
+	caldavPasswordCredential = "postgang-caldav-password"
+	maxPropfindLength        = 1 << 20
+)
+
+const propfindETags = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getetag/></d:prop></d:propfind>`
+
+// caldavFlags holds the command line flags for publishing to CalDAV
+// collections.
+type caldavFlags struct {
+	user         string
+	passwordFile string
+	statePath    string
+}
+
+func (f *caldavFlags) register(cmd *flag.FlagSet) {
+	cmd.StringVar(&f.user, "caldav-user", "", "Log in to CalDAV servers as `name`")
+	cmd.StringVar(&f.passwordFile, "caldav-password-file", "", "Read the CalDAV password from `file`")
+	cmd.StringVar(&f.statePath, "caldav-state", "",
+		"Remember the ETags of published events in `file`, required with -caldav-url; other resources are left alone")
+}
+
+// login returns the user and password for the CalDAV server at u. The
+// user is taken from the flags or the configuration. The password is
+// taken, in order of precedence, from the file given by the flags, the
+// environment, the systemd credentials directory and the
+// configuration. The ~/.netrc entry for the host fills in what is
+// still missing. No user means no authentication.
+func (f *caldavFlags) login(u *url.URL, configuredUser, configuredPassword string) (user, password string, err error) {
+	user = f.user
+	if user == "" {
+		user = configuredUser
+	}
+	if password, err = findCredential(f.passwordFile, caldavPasswordEnv, caldavPasswordCredential, configuredPassword); err != nil {
+		return "", "", err
+	}
+	if user == "" || password == "" {
+		var login, secret string
+		if login, secret, err = netrcCredentials(u.Hostname()); err != nil {
+			return "", "", err
+		}
+		if user == "" {
+			user = login
+		}
+		if password == "" {
+			password = secret
+		}
+	}
+	if user != "" && password == "" {
+		return "", "", fmt.Errorf("CalDAV password for %s not set, use -caldav-password-file, $%s or ~/.netrc", u.Host, caldavPasswordEnv)
+	}
+	return user, password, nil
+}
+
+// publisher returns a caldavPublisher using the HTTP settings of
+// clientArgs without the cache, retries and recordings, which are for
+// the Bring API.
+func (f *caldavFlags) publisher(clientArgs *clientFlags, cfg *configT) (*caldavPublisher, error) {
+	transport, err := clientArgs.transport()
+	if err != nil {
+		return nil, err
+	}
+	statePath := f.statePath
+	if statePath == "" {
+		statePath = cfg.CalDAVState
+	}
+	if statePath == "" {
+		return nil, errors.New("-caldav-url needs -caldav-state to know which events it published")
+	}
+	return &caldavPublisher{
+		client: &http.Client{Transport: transport, Timeout: clientArgs.timeout},
+		login: func(u *url.URL) (string, string, error) {
+			return f.login(u, cfg.CalDAVUser, cfg.CalDAVPassword)
+		},
+		statePath: statePath,
+	}, nil
+}
+
+// caldavPublisher keeps CalDAV collections in sync with the generated
+// events, one calendar object resource per event named after its UID.
+type caldavPublisher struct {
+	client *http.Client
+	// login returns the credentials for a collection.
+	login     func(u *url.URL) (user, password string, err error)
+	statePath string
+}
+
+// caldavState maps resource URLs to the ETags they had after they were
+// last published.
+type caldavState map[string]string
+
+func readCalDAVState(path string) (caldavState, error) {
+	state := caldavState{}
+	if path == "" {
+		return state, nil
+	}
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(bs, &state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return state, nil
+}
+
+func (state caldavState) write(path string) error {
+	if path == "" {
+		return nil
+	}
+	bs, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(bs, '\n'))
+}
+
+// errConflict is returned when a resource was changed by someone else.
+var errConflict = errors.New("changed on the server")
+
+// caldavSession is one run against a collection.
+type caldavSession struct {
+	*caldavPublisher
+	collection     *url.URL
+	user, password string
+	state          caldavState
+}
+
+func (s *caldavSession) request(ctx context.Context, method string, u *url.URL, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.user != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+	return req, nil
+}
+
+// do sends the request, returning an error for other status codes than
+// those accepted.
+func (s *caldavSession) do(req *http.Request, accepted ...int) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range accepted {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return nil, errConflict
+	}
+	return nil, fmt.Errorf("%s %s: got HTTP error: %s", req.Method, req.URL, resp.Status)
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href  string   `xml:"DAV: href"`
+		ETags []string `xml:"DAV: propstat>prop>getetag"`
+	} `xml:"DAV: response"`
+}
+
+// propfind returns the ETags of u, and its members for depth 1, by
+// path.
+func (s *caldavSession) propfind(ctx context.Context, u *url.URL, depth string) (map[string]string, error) {
+	req, err := s.request(ctx, "PROPFIND", u, []byte(propfindETags))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := s.do(req, http.StatusMultiStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var status multistatus
+	if err = xml.NewDecoder(io.LimitReader(resp.Body, maxPropfindLength)).Decode(&status); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: %w", u, err)
+	}
+	etags := map[string]string{}
+	for _, r := range status.Responses {
+		href, err := u.Parse(r.Href)
+		if err != nil {
+			return nil, fmt.Errorf("PROPFIND %s: %w", u, err)
+		}
+		var etag string
+		for _, e := range r.ETags {
+			if e != "" {
+				etag = e
+			}
+		}
+		etags[path.Clean(href.Path)] = etag
+	}
+	return etags, nil
+}
+
+// list returns the ETags of the resources in the collection by name.
+func (s *caldavSession) list(ctx context.Context) (map[string]string, error) {
+	etags, err := s.propfind(ctx, s.collection, "1")
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]string{}
+	for p, etag := range etags {
+		if p != path.Clean(s.collection.Path) {
+			byName[path.Base(p)] = etag
+		}
+	}
+	return byName, nil
+}
+
+// etag returns the ETag of the resource at u, empty if the server has
+// none.
+func (s *caldavSession) etag(ctx context.Context, u *url.URL) (string, error) {
+	etags, err := s.propfind(ctx, u, "0")
+	if err != nil {
+		return "", err
+	}
+	return etags[path.Clean(u.Path)], nil
+}
+
+func (s *caldavSession) resourceURL(name string) *url.URL {
+	return s.collection.JoinPath(url.PathEscape(name))
+}
+
+// checkUnchanged returns errConflict unless the ETag of the resource on
+// the server is the one it got when last published. Resources not
+// published by us, or whose ETag was never known, are not ours to
+// change.
+func (s *caldavSession) checkUnchanged(u *url.URL, etag string) error {
+	if known, ok := s.state[u.String()]; !ok || etag == "" || known != etag {
+		return errConflict
+	}
+	return nil
+}
+
+// put creates the resource, or updates it when it is unchanged since
+// it was last published.
+func (s *caldavSession) put(ctx context.Context, name string, body []byte, etag string, exists bool) error {
+	u := s.resourceURL(name)
+	if exists {
+		if err := s.checkUnchanged(u, etag); err != nil {
+			return err
+		}
+	}
+	req, err := s.request(ctx, http.MethodPut, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	if exists {
+		req.Header.Set("If-Match", etag)
+	} else {
+		req.Header.Set("If-None-Match", "*")
+	}
+	resp, err := s.do(req, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// Servers storing something else than what was sent leave out
+	// the ETag, ask for it.
+	etag = resp.Header.Get("ETag")
+	if etag == "" {
+		if etag, err = s.etag(ctx, u); err != nil {
+			delete(s.state, u.String())
+			return fmt.Errorf("%s: unable to get ETag after PUT: %w", u, err)
+		}
+	}
+	if etag != "" {
+		s.state[u.String()] = etag
+	} else {
+		// Without an ETag the resource is left alone from now on.
+		delete(s.state, u.String())
+	}
+	return nil
+}
+
+// remove deletes the resource when it is unchanged since it was last
+// published.
+func (s *caldavSession) remove(ctx context.Context, name, etag string) error {
+	u := s.resourceURL(name)
+	if err := s.checkUnchanged(u, etag); err != nil {
+		return err
+	}
+	req, err := s.request(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("If-Match", etag)
+	resp, err := s.do(req, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	delete(s.state, u.String())
+	return nil
+}
+
+// publish makes the collection hold the resources, deleting other
+// resources for which owns is true. Existing resources are only
+// changed when the state shows we published them as they are, others
+// are left alone and logged.
+func (p *caldavPublisher) publish(
+	ctx context.Context, collection *url.URL, resources map[string][]byte, owns func(name string) bool,
+) error {
+	user, password, err := p.login(collection)
+	if err != nil {
+		return err
+	}
+	state, err := readCalDAVState(p.statePath)
+	if err != nil {
+		return err
+	}
+	s := &caldavSession{caldavPublisher: p, collection: collection, user: user, password: password, state: state}
+	existing, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	report := func(name string, err error) {
+		if errors.Is(err, errConflict) {
+			log.Printf("%s: %s, leaving it alone", s.resourceURL(name), err)
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, name := range names {
+		etag, exists := existing[name]
+		report(name, s.put(ctx, name, resources[name], etag, exists))
+	}
+	var removed []string
+	for name := range existing {
+		if _, ok := resources[name]; !ok && owns(name) {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		report(name, s.remove(ctx, name, existing[name]))
+	}
+	if err = state.write(p.statePath); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// publishCalendars publishes the events of the calendars to the
+// collection, each in a resource of its own.
+func (p *caldavPublisher) publishCalendars(ctx context.Context, collection *url.URL, cals []*calendarT) error {
+	merged := mergeCalendars(cals...)
+	resources := map[string][]byte{}
+	for _, event := range merged.Events() {
+		var buf bytes.Buffer
+		if err := ical.NewContentPrinter(&buf).PrintContext(ctx, ical.CalendarObject(merged.Single(event))).Error(); err != nil {
+			return err
+		}
+		resources[event.UID().Value()+icsSuffix] = buf.Bytes()
+	}
+	owns := func(name string) bool {
+		uid := strings.TrimSuffix(name, icsSuffix)
+		for _, cal := range cals {
+			if uid != name && cal.ownsUID(uid, len(cals) > 1) {
+				return true
+			}
+		}
+		return false
+	}
+	return p.publish(ctx, collection, resources, owns)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeResource struct {
+	etag string
+	body string
+}
+
+// fakeCalDAV is a CalDAV collection at /cal/ supporting what the
+// publisher needs: PROPFIND of ETags, and conditional PUT and DELETE.
+type fakeCalDAV struct {
+	mu        sync.Mutex
+	user      string
+	password  string
+	resources map[string]*fakeResource
+	etags     int
+	// noETag leaves the ETag out of PUT responses, like servers
+	// changing the stored data do.
+	noETag bool
+	// conditions are the conditional headers of PUT and DELETE
+	// requests by resource.
+	conditions map[string][]string
+}
+
+func newFakeCalDAV(t *testing.T) (*fakeCalDAV, *httptest.Server) {
+	dav := &fakeCalDAV{user: "user", password: "secret", resources: map[string]*fakeResource{}, conditions: map[string][]string{}}
+	server := httptest.NewServer(dav)
+	t.Cleanup(server.Close)
+	return dav, server
+}
+
+// set stores a resource as another client would.
+func (dav *fakeCalDAV) set(name, body string) {
+	dav.mu.Lock()
+	defer dav.mu.Unlock()
+	dav.etags++
+	dav.resources[name] = &fakeResource{etag: fmt.Sprintf(`"%d"`, dav.etags), body: body}
+}
+
+func (dav *fakeCalDAV) get(name string) *fakeResource {
+	dav.mu.Lock()
+	defer dav.mu.Unlock()
+	return dav.resources[name]
+}
+
+func (dav *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dav.mu.Lock()
+	defer dav.mu.Unlock()
+	if user, password, ok := r.BasicAuth(); !ok || user != dav.user || password != dav.password {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	name := path.Base(r.URL.Path)
+	resource := dav.resources[name]
+	if r.Method == "PROPFIND" {
+		dav.propfind(w, r, name, resource)
+		return
+	}
+	ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	dav.conditions[name] = append(dav.conditions[name], r.Method+" "+ifMatch+ifNoneMatch)
+	if (ifNoneMatch == "*" && resource != nil) || (ifMatch != "" && (resource == nil || resource.etag != ifMatch)) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		dav.etags++
+		dav.resources[name] = &fakeResource{etag: fmt.Sprintf(`"%d"`, dav.etags), body: string(body)}
+		if !dav.noETag {
+			w.Header().Set("ETag", dav.resources[name].etag)
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		delete(dav.resources, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (dav *fakeCalDAV) propfind(w http.ResponseWriter, r *http.Request, name string, resource *fakeResource) {
+	const entry = `<response><href>/cal/%s</href><propstat><prop><getetag>%s</getetag></prop>` +
+		`<status>HTTP/1.1 200 OK</status></propstat></response>`
+	if r.URL.Path != "/cal/" {
+		if resource == nil || r.Header.Get("Depth") != "0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0"?><multistatus xmlns="DAV:">`+entry+`</multistatus>`, url.PathEscape(name), resource.etag)
+		return
+	}
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprint(w, `<?xml version="1.0"?><multistatus xmlns="DAV:"><response><href>/cal/</href></response>`)
+	for name, resource := range dav.resources {
+		fmt.Fprintf(w, entry, url.PathEscape(name), resource.etag)
+	}
+	fmt.Fprint(w, `</multistatus>`)
+}
+
+func testPublisher(server *httptest.Server, password, statePath string) *caldavPublisher {
+	return &caldavPublisher{
+		client: server.Client(),
+		login: func(*url.URL) (string, string, error) {
+			return "user", password, nil
+		},
+		statePath: statePath,
+	}
+}
+
+func collectionURL(t *testing.T, server *httptest.Server) *url.URL {
+	u, err := url.Parse(server.URL + "/cal/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestPublishCalDAV(t *testing.T) {
+	dav, server := newFakeCalDAV(t)
+	dav.set("other.ics", "someone else's")
+	publisher := testPublisher(server, "secret", filepath.Join(t.TempDir(), "caldav.json"))
+	cal := calendarTFixture()
+	if err := publisher.publishCalendars(context.Background(), collectionURL(t, server), []*calendarT{cal}); err != nil {
+		t.Fatal(err)
+	}
+	if len(dav.resources) != len(cal.dates)+1 {
+		t.Fatalf("Expected %d resources, got %d", len(cal.dates)+1, len(dav.resources))
+	}
+	first := dav.get("postgang-20211228@test.ics")
+	if first == nil || !strings.Contains(first.body, "UID:postgang-20211228@test\r\n") || strings.Contains(first.body, "METHOD:") {
+		t.Fatalf("Unexpected resource %+v", first)
+	}
+	if n := strings.Count(first.body, "BEGIN:VEVENT"); n != 1 {
+		t.Fatalf("Expected one event, got %d", n)
+	}
+
+	cal.dates = cal.dates[1:]
+	if err := publisher.publishCalendars(context.Background(), collectionURL(t, server), []*calendarT{cal}); err != nil {
+		t.Fatal(err)
+	}
+	if dav.get("postgang-20211228@test.ics") != nil {
+		t.Fatal("Removed date not deleted")
+	}
+	if dav.get("other.ics") == nil {
+		t.Fatal("Foreign resource deleted")
+	}
+	for name, expected := range map[string][]string{
+		"postgang-20211228@test.ics": {"PUT *", `DELETE "2"`},
+		"postgang-20211229@test.ics": {"PUT *", `PUT "3"`},
+	} {
+		if got := dav.conditions[name]; strings.Join(got, ",") != strings.Join(expected, ",") {
+			t.Fatalf("%s: %q != %q", name, got, expected)
+		}
+	}
+}
+
+func TestPublishCalDAVLeavesEditsAlone(t *testing.T) {
+	dav, server := newFakeCalDAV(t)
+	publisher := testPublisher(server, "secret", filepath.Join(t.TempDir(), "caldav.json"))
+	cal := calendarTFixture()
+	if err := publisher.publishCalendars(context.Background(), collectionURL(t, server), []*calendarT{cal}); err != nil {
+		t.Fatal(err)
+	}
+	dav.set("postgang-20211228@test.ics", "edited")
+	dav.set("postgang-20211229@test.ics", "edited")
+	logged := captureLog(t)
+	cal.dates = cal.dates[1:]
+	if err := publisher.publishCalendars(context.Background(), collectionURL(t, server), []*calendarT{cal}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"postgang-20211228@test.ics", "postgang-20211229@test.ics"} {
+		if got := dav.get(name); got == nil || got.body != "edited" {
+			t.Fatalf("%s: edit not kept: %+v", name, got)
+		}
+	}
+	if n := strings.Count(logged.String(), "changed on the server"); n != 2 {
+		t.Fatalf("Expected 2 conflicts logged, got %d:\n%s", n, logged)
+	}
+	if !strings.Contains(dav.get("postgang-20211230@test.ics").body, "UID:postgang-20211230@test") {
+		t.Fatal("Unchanged resource not published")
+	}
+}
+
+func TestPublishCalDAVWithoutPutETag(t *testing.T) {
+	dav, server := newFakeCalDAV(t)
+	dav.noETag = true
+	publisher := testPublisher(server, "secret", filepath.Join(t.TempDir(), "caldav.json"))
+	cal := calendarTFixture()
+	if err := publisher.publishCalendars(context.Background(), collectionURL(t, server), []*calendarT{cal}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := readCalDAVState(publisher.statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state) != len(cal.dates) {
+		t.Fatalf("Expected %d ETags in state, got %v", len(cal.dates), state)
+	}
+	logged := captureLog(t)
+	cal.dates = cal.dates[1:]
+	if err = publisher.publishCalendars(context.Background(), collectionURL(t, server), []*calendarT{cal}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logged.String(), "changed on the server") {
+		t.Fatalf("Unexpected conflicts:\n%s", logged)
+	}
+	if dav.get("postgang-20211228@test.ics") != nil {
+		t.Fatal("Removed date not deleted")
+	}
+	if got := dav.conditions["postgang-20211229@test.ics"]; len(got) != 2 || got[1] == "PUT " {
+		t.Fatalf("Unexpected conditions %q", got)
+	}
+}
+
+func TestPublishCalDAVWithoutState(t *testing.T) {
+	dav, server := newFakeCalDAV(t)
+	dav.set("postgang-20211228@test.ics", "published by another host")
+	dav.set("postgang-20211201@test.ics", "published by another host")
+	publisher := testPublisher(server, "secret", filepath.Join(t.TempDir(), "caldav.json"))
+	logged := captureLog(t)
+	if err := publisher.publishCalendars(context.Background(), collectionURL(t, server), []*calendarT{calendarTFixture()}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"postgang-20211228@test.ics", "postgang-20211201@test.ics"} {
+		if got := dav.get(name); got == nil || got.body != "published by another host" {
+			t.Fatalf("%s: unknown resource changed: %+v", name, got)
+		}
+	}
+	if n := strings.Count(logged.String(), "changed on the server"); n != 2 {
+		t.Fatalf("Expected 2 conflicts logged, got %d:\n%s", n, logged)
+	}
+	if dav.get("postgang-20211229@test.ics") == nil {
+		t.Fatal("New resource not published")
+	}
+}
+
+func TestPublishCalDAVUnauthorized(t *testing.T) {
+	_, server := newFakeCalDAV(t)
+	publisher := testPublisher(server, "wrong", filepath.Join(t.TempDir(), "caldav.json"))
+	err := publisher.publishCalendars(context.Background(), collectionURL(t, server), []*calendarT{calendarTFixture()})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Expected 401, got %v", err)
+	}
+}
+
+func TestOwnsUID(t *testing.T) {
+	cal := calendarTFixture()
+	for uid, expected := range map[string]bool{
+		"postgang-20211228@test":      true,
+		"postgang-6666-20211228@test": false,
+		"postgang-20211228@other":     false,
+		"postgang-notadate@test":      false,
+		"other-20211228@test":         false,
+	} {
+		if got := cal.ownsUID(uid, false); got != expected {
+			t.Fatalf("%s: %t != %t", uid, got, expected)
+		}
+	}
+	if !cal.ownsUID("postgang-6666-20211228@test", true) || cal.ownsUID("postgang-1234-20211228@test", true) {
+		t.Fatal("Unexpected ownership with code")
+	}
+}
+
+func TestRunJobCalDAV(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv(caldavPasswordEnv, "secret")
+	dav, server := newFakeCalDAV(t)
+	args, err := parseArgs(commandLine(), []string{
+		"--code=6666", "--provider=static", "--hostname=test", "--caldav-url", server.URL + "/cal/", "--caldav-user", "user",
+		"--caldav-state", filepath.Join(t.TempDir(), "caldav.json"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = args.runJob(context.Background(), args.jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(dav.resources) == 0 {
+		t.Fatal("Nothing published")
+	}
+}
+
+func TestParseArgsCalDAVErrors(t *testing.T) {
+	for _, a := range [][]string{
+		{"--caldav-url", "/cal/"},
+		{"--caldav-url", "https://example.com/cal/"},
+		{"--caldav-url", "https://example.com/cal/", "--output", "6666.ics"},
+		{"--caldav-url", "https://example.com/cal/", "--format", "jcal"},
+	} {
+		if _, err := parseArgs(commandLine(), append([]string{"--code=6666", "--provider=static"}, a...)); err == nil {
+			t.Fatalf("Expected error for %v", a)
+		}
+	}
+}
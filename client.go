@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const defaultHTTPTimeout = 30 * time.Second
+
+var bringAPIURL = func() *url.URL {
+	if u, err := url.Parse("https://" + apiHost + "/"); err != nil {
+		panic(err)
+	} else {
+		return u
+	}
+}()
+
+// clientFlags holds the command line flags configuring the HTTP client
+// used to talk to the Bring API.
+type clientFlags struct {
+	apiURL        string
+	timeout       time.Duration
+	proxy         string
+	caFile        string
+	userAgent     string
+	cacheDir      string
+	cacheMaxAge   time.Duration
+	attempts      int
+	retryDeadline time.Duration
+	recordDir     string
+	replayDir     string
+}
+
+func (f *clientFlags) register(cmd *flag.FlagSet) {
+	cmd.StringVar(&f.apiURL, "api-url", bringAPIURL.String(), "Base `URL` of the Bring API")
+	cmd.DurationVar(&f.timeout, "http-timeout", defaultHTTPTimeout, "Give up on each API request attempt after `duration`, 0 waits forever")
+	cmd.StringVar(&f.proxy, "proxy", "", "Use proxy `URL` for API requests instead of $HTTPS_PROXY")
+	cmd.StringVar(&f.caFile, "ca-file", "", "Trust the PEM encoded CA certificates in `file` in addition to the system ones")
+	cmd.StringVar(&f.userAgent, "user-agent", "", "Send `string` as User-Agent")
+	cmd.StringVar(&f.cacheDir, "cache-dir", "", "Cache API responses in `directory`")
+	cmd.DurationVar(&f.cacheMaxAge, "cache-max-age", 0, "Use cached responses younger than `duration` without asking the API")
+	cmd.IntVar(&f.attempts, "attempts", defaultAttempts, "Try failing API requests at most `count` times")
+	cmd.DurationVar(&f.retryDeadline, "retry-deadline", defaultRetryDeadline, "Stop retrying API requests after `duration`")
+	cmd.StringVar(&f.recordDir, "record", "", "Save API requests and responses, without credentials, in `directory`")
+	cmd.StringVar(&f.replayDir, "replay", "", "Answer API requests with the responses saved in `directory` by -record")
+}
+
+func (f *clientFlags) baseURL() (*url.URL, error) {
+	u, err := url.Parse(f.apiURL)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("API URL must be absolute: %s", f.apiURL)
+	}
+	return u, nil
+}
+
+func (f *clientFlags) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if f.caFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(f.caFile)
+	if err != nil {
+		return nil, err
+	}
+	if config.RootCAs, err = x509.SystemCertPool(); err != nil {
+		config.RootCAs = x509.NewCertPool()
+	}
+	if !config.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", f.caFile)
+	}
+	return config, nil
+}
+
+// baseTransport returns the network transport given by the proxy and
+// TLS flags.
+func (f *clientFlags) baseTransport() (*http.Transport, error) {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if f.proxy != "" {
+		proxyURL, err := url.Parse(f.proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		base.Proxy = http.ProxyURL(proxyURL)
+	}
+	tlsConfig, err := f.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	base.TLSClientConfig = tlsConfig
+	return base, nil
+}
+
+// withUserAgent returns transport sending the User-Agent given by the
+// flags.
+func (f *clientFlags) withUserAgent(transport http.RoundTripper) http.RoundTripper {
+	userAgent := f.userAgent
+	if userAgent == "" {
+		userAgent = fmt.Sprintf("postgang/%s", version)
+	}
+	return &userAgentTransport{userAgent: userAgent, transport: transport}
+}
+
+// transport returns the network transport given by the proxy, TLS
+// and User-Agent flags.
+func (f *clientFlags) transport() (http.RoundTripper, error) {
+	base, err := f.baseTransport()
+	if err != nil {
+		return nil, err
+	}
+	return f.withUserAgent(base), nil
+}
+
+func (f *clientFlags) client() (*http.Client, error) {
+	if f.recordDir != "" && f.replayDir != "" {
+		return nil, errors.New("-record and -replay are mutually exclusive")
+	}
+	base, err := f.baseTransport()
+	if err != nil {
+		return nil, err
+	}
+	// The recorder sits below the User-Agent, so recordings have the
+	// request as it was sent.
+	var transport http.RoundTripper = base
+	switch {
+	case f.recordDir != "":
+		transport = &recordTransport{dir: f.recordDir, transport: transport}
+	case f.replayDir != "":
+		transport = &replayTransport{dir: f.replayDir}
+	}
+	transport = f.withUserAgent(transport)
+	// A replay gives the same answer every time, so retrying is futile.
+	if f.replayDir == "" {
+		transport = newRetryTransport(f.attempts, f.retryDeadline, f.timeout, transport)
+	}
+	if f.cacheDir != "" {
+		maxAge := f.cacheMaxAge
+		if f.recordDir != "" {
+			// Every request must reach the recorder.
+			maxAge = 0
+		}
+		transport = newCacheTransport(f.cacheDir, maxAge, transport)
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// userAgentTransport sets the User-Agent header of requests without
+// one.
+type userAgentTransport struct {
+	userAgent string
+	transport http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// providerOptions returns the provider options with the HTTP client and
+// API URL given by the flags, and the credentials for the API host.
+func (f *clientFlags) providerOptions(creds *credentialFlags, configured credentials) (*providerOptions, error) {
+	client, err := f.client()
+	if err != nil {
+		return nil, err
+	}
+	baseURL, err := f.baseURL()
+	if err != nil {
+		return nil, err
+	}
+	return &providerOptions{client: client, baseURL: baseURL, credentials: creds.lookup(baseURL.Hostname(), configured)}, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixtureBackend(t *testing.T, userAgent *string) http.Handler {
+	bs := readFixture("test/fixture.json", t)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*userAgent = r.Header.Get("User-Agent")
+		if r.URL.Path != "/bring/address/api/no/postal-codes/6666/mailbox-delivery-dates" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(bs)
+	})
+}
+
+func TestClientAPIURL(t *testing.T) {
+	t.Setenv(apiUIDEnv, "uid")
+	t.Setenv(apiKeyEnv, "key")
+	var userAgent string
+	server := httptest.NewServer(fixtureBackend(t, &userAgent))
+	defer server.Close()
+	got, err := parseArgs(commandLine(), []string{"--code=6666", "--api-url", server.URL + "/bring/", "--user-agent", "test/1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := got.provider.fetch(context.Background(), postalCode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dataFixture(t)) {
+		t.Fatalf("\n%+v\n\n!=\n\n%+v", data, dataFixture(t))
+	}
+	if userAgent != "test/1.0" {
+		t.Fatalf("Unexpected User-Agent %q", userAgent)
+	}
+}
+
+func TestClientDefaultUserAgent(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(fixtureBackend(t, &userAgent))
+	defer server.Close()
+	client, err := (&clientFlags{}).client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if userAgent != "postgang/"+version {
+		t.Fatalf("Unexpected User-Agent %q", userAgent)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	client, err := (&clientFlags{timeout: 50 * time.Millisecond}).client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected timeout")
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+	client, err := (&clientFlags{proxy: proxy.URL}).client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("http://api.example.com/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if proxied != "http://api.example.com/test" {
+		t.Fatalf("Unexpected proxied URL %q", proxied)
+	}
+}
+
+func TestClientCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	untrusted, err := (&clientFlags{}).client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := untrusted.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected certificate error")
+	}
+	trusted, err := (&clientFlags{caFile: caFile}).client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := trusted.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestClientFlagErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"--code=6666", "--api-url", "/relative"},
+		{"--code=6666", "--proxy", "http://[::1"},
+		{"--code=6666", "--ca-file", filepath.Join(t.TempDir(), "missing.pem")},
+	} {
+		if _, err := parseArgs(commandLine(), args); err == nil {
+			t.Fatalf("Expected error for %s", strings.Join(args, " "))
+		}
+	}
+	emptyCA := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(emptyCA, []byte("nothing"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&clientFlags{caFile: emptyCA}).client(); err == nil {
+		t.Fatal("Expected error for CA file without certificates")
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"text/template"
+	"time"
+)
+
+// jobConfig is one calendar to generate. Empty settings are taken
+// from the top level of the configuration file.
+type jobConfig struct {
+	Code     string `json:"code"`
+	Output   string `json:"output"`
+	Hostname string `json:"hostname"`
+	Language string `json:"language"`
+	Summary  string `json:"summary"`
+	// Alarms are durations like -PT12H or times of day like 07:30.
+	Alarms []string `json:"alarms"`
+	// Window is the delivery window, like 10:00-14:00, for timed
+	// events instead of all-day events.
+	Window string `json:"window"`
+	Format string `json:"format"`
+	// CalDAV is the URL of a CalDAV collection to publish the events
+	// to instead of writing Output.
+	CalDAV string `json:"caldav_url"`
+	// State is the file keeping the dates published by previous runs.
+	State string `json:"state"`
+	// Snapshot is the file keeping the delivery dates of the last run,
+	// compared with the new ones to send Notify.
+	Snapshot string `json:"snapshot"`
+	// Notify are where to send changes: stdout, webhook URLs or
+	// command:COMMAND.
+	Notify []string `json:"notify"`
+	// SummaryTemplate and DescriptionTemplate are text/template
+	// templates executed with eventData.
+	SummaryTemplate     string `json:"summary_template"`
+	DescriptionTemplate string `json:"description_template"`
+}
+
+// configT is the configuration file. The embedded jobConfig holds the
+// defaults for all jobs, and is the only job when Jobs is empty.
+type configT struct {
+	jobConfig
+	Provider   string       `json:"provider"`
+	PlaceNames bool         `json:"place_names"`
+	APIUID     string       `json:"api_uid"`
+	APIKey     string       `json:"api_key"`
+	Jobs       []*jobConfig `json:"jobs"`
+
+	// CalDAVUser and CalDAVPassword log in to CalDAV servers.
+	CalDAVUser     string `json:"caldav_user"`
+	CalDAVPassword string `json:"caldav_password"`
+	CalDAVState    string `json:"caldav_state"`
+}
+
+func readConfig(path string) (*configT, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	checkPermissions(file)
+	var cfg configT
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func overrideString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+// merge returns the job with empty settings taken from defaults.
+func (job *jobConfig) merge(defaults *jobConfig) *jobConfig {
+	merged := *defaults
+	overrideString(&merged.Code, job.Code)
+	overrideString(&merged.Output, job.Output)
+	overrideString(&merged.Hostname, job.Hostname)
+	overrideString(&merged.Language, job.Language)
+	// A summary and a summary template compete, job replaces both
+	// when it gives either.
+	if job.Summary != "" || job.SummaryTemplate != "" {
+		merged.Summary, merged.SummaryTemplate = job.Summary, job.SummaryTemplate
+	}
+	overrideString(&merged.DescriptionTemplate, job.DescriptionTemplate)
+	overrideString(&merged.Window, job.Window)
+	overrideString(&merged.Format, job.Format)
+	overrideString(&merged.CalDAV, job.CalDAV)
+	overrideString(&merged.State, job.State)
+	overrideString(&merged.Snapshot, job.Snapshot)
+	if job.Alarms != nil {
+		merged.Alarms = job.Alarms
+	}
+	if job.Notify != nil {
+		merged.Notify = job.Notify
+	}
+	return &merged
+}
+
+// jobT is a calendar to generate.
+type jobT struct {
+	codes      postalCodesT
+	outputPath string
+	hostname   string
+	language   *languageT
+	summary    string
+	alarms     []time.Duration
+	window     *windowT
+	format     string
+	// caldavURL is the collection to publish to, when set.
+	caldavURL    *url.URL
+	statePath    string
+	snapshotPath string
+	notify       []string
+	// summaryTemplate and descriptionTemplate are nil unless given.
+	summaryTemplate     *template.Template
+	descriptionTemplate *template.Template
+}
+
+func (job *jobConfig) toJob() (*jobT, error) {
+	var codes postalCodesT
+	if job.Code != "" {
+		if err := codes.Set(job.Code); err != nil {
+			return nil, err
+		}
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("no postal code given")
+	}
+	language := job.Language
+	if language == "" {
+		language = defaultLanguage
+	}
+	lang, err := toLanguage(language)
+	if err != nil {
+		return nil, err
+	}
+	if job.Summary != "" {
+		if err = checkSummaryFormat(job.Summary); err != nil {
+			return nil, err
+		}
+	}
+	// merge keeps both only when they come from the same place.
+	if job.Summary != "" && job.SummaryTemplate != "" {
+		return nil, fmt.Errorf("summary and summary template can not both be given")
+	}
+	var summaryTemplate, descriptionTemplate *template.Template
+	if job.SummaryTemplate != "" {
+		if summaryTemplate, err = parseEventTemplate("summary", job.SummaryTemplate); err != nil {
+			return nil, err
+		}
+	}
+	if job.DescriptionTemplate != "" {
+		if descriptionTemplate, err = parseEventTemplate("description", job.DescriptionTemplate); err != nil {
+			return nil, err
+		}
+	}
+	var alarms []time.Duration
+	for _, alarm := range job.Alarms {
+		trigger, err := parseAlarm(alarm)
+		if err != nil {
+			return nil, err
+		}
+		alarms = append(alarms, trigger)
+	}
+	var window *windowT
+	if job.Window != "" {
+		if window, err = parseWindow(job.Window); err != nil {
+			return nil, err
+		}
+	}
+	format := job.Format
+	if format == "" {
+		format = formatICS
+	}
+	if err = checkFormat(format); err != nil {
+		return nil, err
+	}
+	outputPath := job.Output
+	if outputPath == "-" {
+		outputPath = ""
+	}
+	var caldavURL *url.URL
+	if job.CalDAV != "" {
+		if caldavURL, err = url.Parse(job.CalDAV); err != nil {
+			return nil, err
+		}
+		switch {
+		case !caldavURL.IsAbs() || caldavURL.Host == "":
+			return nil, fmt.Errorf("CalDAV URL must be absolute: %s", job.CalDAV)
+		case job.Output != "":
+			return nil, fmt.Errorf("output and CalDAV URL can not both be given")
+		case format != formatICS:
+			return nil, fmt.Errorf("format %s can not be published to CalDAV", format)
+		}
+	}
+	for _, spec := range job.Notify {
+		if _, err = newNotifier(spec, nil); err != nil {
+			return nil, err
+		}
+		if spec == notifyStdout && outputPath == "" && caldavURL == nil {
+			return nil, fmt.Errorf("changes can not be sent to stdout with the calendar")
+		}
+	}
+	if (job.Snapshot == "") != (len(job.Notify) == 0) {
+		return nil, fmt.Errorf("snapshot and notify must be given together")
+	}
+	return &jobT{
+		codes:               codes,
+		outputPath:          outputPath,
+		hostname:            job.Hostname,
+		language:            lang,
+		summary:             job.Summary,
+		alarms:              alarms,
+		window:              window,
+		format:              format,
+		caldavURL:           caldavURL,
+		statePath:           job.State,
+		snapshotPath:        job.Snapshot,
+		notify:              job.Notify,
+		summaryTemplate:     summaryTemplate,
+		descriptionTemplate: descriptionTemplate,
+	}, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "postgang.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const configFixture = `{
+  "hostname": "example.com",
+  "language": "nn",
+  "provider": "static",
+  "jobs": [
+    {"code": "6666", "output": "/tmp/6666.ics"},
+    {"code": "1234,42", "output": "/tmp/work.ics", "hostname": "work.example.com", "language": "en"},
+    {"code": "1", "summary": "%s: Post %s %d"}
+  ]
+}`
+
+func TestParseArgsConfig(t *testing.T) {
+	got, err := parseArgs(commandLine(), []string{"--config", writeConfig(t, configFixture)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.jobs) != 3 {
+		t.Fatalf("Expected 3 jobs, got %d", len(got.jobs))
+	}
+	expected := []jobT{
+		{codes: got.jobs[0].codes, outputPath: "/tmp/6666.ics", hostname: "example.com", language: languages["nn"], format: formatICS},
+		{codes: got.jobs[1].codes, outputPath: "/tmp/work.ics", hostname: "work.example.com", language: languages["en"], format: formatICS},
+		{codes: got.jobs[2].codes, hostname: "example.com", language: languages["nn"], summary: "%s: Post %s %d", format: formatICS},
+	}
+	for i, job := range got.jobs {
+		if !reflect.DeepEqual(*job, expected[i]) {
+			t.Fatalf("Job %d: %+v != %+v", i, *job, expected[i])
+		}
+	}
+	if got.jobs[1].codes.String() != "1234,0042" {
+		t.Fatalf("Unexpected codes %s", got.jobs[1].codes)
+	}
+	if _, ok := got.provider.(*readerProvider); !ok {
+		t.Fatalf("Expected static provider, got %T", got.provider)
+	}
+}
+
+func TestParseArgsConfigFlagsTakePrecedence(t *testing.T) {
+	args := []string{"--config", writeConfig(t, configFixture), "--hostname", "flag", "--language", "nb", "--provider", "bring"}
+	got, err := parseArgs(commandLine(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range got.jobs {
+		if job.hostname != "flag" || job.language != languages["nb"] {
+			t.Fatalf("Flags not applied: %+v", *job)
+		}
+	}
+	if _, ok := got.provider.(*bringProvider); !ok {
+		t.Fatalf("Expected bring provider, got %T", got.provider)
+	}
+}
+
+func TestParseArgsConfigCodeFlag(t *testing.T) {
+	got, err := parseArgs(commandLine(), []string{"--config", writeConfig(t, configFixture), "--code", "6666"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.jobs) != 1 || got.jobs[0].hostname != "example.com" || got.jobs[0].outputPath != "" {
+		t.Fatalf("Unexpected jobs %+v", got.jobs)
+	}
+}
+
+func TestParseArgsConfigOutputWithSeveralJobs(t *testing.T) {
+	_, err := parseArgs(commandLine(), []string{"--config", writeConfig(t, configFixture), "--output", "x.ics"})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestParseArgsConfigErrors(t *testing.T) {
+	for _, content := range []string{
+		`{"code": "6666", "unknown": true}`,
+		`{"code": "6666", "language": "sv"}`,
+		`{"code": "6666", "summary": "%s %s %d %d"}`,
+		`{"code": "99999"}`,
+		`{"code": "6666", "alarms": ["25:00"]}`,
+		`{"code": "6666", "alarms": ["PT"]}`,
+		`{"code": "6666", "format": "csv"}`,
+		`{"code": "6666", "summary_template": "{{.Town}}"}`,
+		`{"language": "nb"}`,
+		`not json`,
+	} {
+		if _, err := parseArgs(commandLine(), []string{"--config", writeConfig(t, content)}); err == nil {
+			t.Fatalf("Expected error for %s", content)
+		}
+	}
+	if _, err := parseArgs(commandLine(), []string{"--config", filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Fatal("Expected error for missing file")
+	}
+}
+
+func TestReadConfigWorldReadable(t *testing.T) {
+	logged := captureLog(t)
+	path := writeConfig(t, configFixture)
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged.String(), "postgang.json is readable by everyone") {
+		t.Fatalf("Expected warning, got %q", logged.String())
+	}
+}
+
+func TestConfigCredentials(t *testing.T) {
+	t.Setenv("POSTGANG_API_UID", "env-uid")
+	t.Setenv("POSTGANG_API_KEY", "")
+	creds, err := envCredentials(credentials{uid: "file-uid", key: "file-key"})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *creds != (credentials{uid: "env-uid", key: "file-key"}) {
+		t.Fatalf("Unexpected credentials %+v", *creds)
+	}
+	if _, err = envCredentials(credentials{})(); err == nil || !strings.Contains(err.Error(), "POSTGANG_API_KEY") {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestRunJobWritesOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.ics")
+	got, err := parseArgs(commandLine(), []string{
+		"--code", "6666", "--provider", "static", "--date", "2021-12-28", "--hostname", "test", "--output", output,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = got.runJob(context.Background(), got.jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != string(readFixture("test/fixture.ics", t)) {
+		t.Fatalf("Unexpected output\n%s", bs)
+	}
+}
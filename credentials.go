@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	apiHost             = "api.bring.com"
+	apiUIDEnv           = "POSTGANG_API_UID"
+	apiKeyEnv           = "POSTGANG_API_KEY"
+	apiUIDCredential    = "postgang-api-uid"
+	apiKeyCredential    = "postgang-api-key"
+	credentialsDirEnv   = "CREDENTIALS_DIRECTORY"
+	netrcEnv            = "NETRC"
+	worldReadableBits   = 0o004
+	maxCredentialLength = 4096
+)
+
+// credentialFlags holds the command line flags for reading Bring API
+// credentials from files.
+type credentialFlags struct {
+	uidFile string
+	keyFile string
+}
+
+func (f *credentialFlags) register(cmd *flag.FlagSet) {
+	cmd.StringVar(&f.uidFile, "api-uid-file", "", "Read Bring API uid from `file`")
+	cmd.StringVar(&f.keyFile, "api-key-file", "", "Read Bring API key from `file`")
+}
+
+// lookup returns a credentialsFunc finding the uid and key. They are
+// taken, in order of precedence, from the files given by the flags,
+// the environment, the systemd credentials directory, the configured
+// credentials and the ~/.netrc entry for host. The credentials are
+// looked up once.
+func (f *credentialFlags) lookup(host string, configured credentials) credentialsFunc {
+	var (
+		once  sync.Once
+		creds *credentials
+		err   error
+	)
+	return func() (*credentials, error) {
+		once.Do(func() {
+			creds, err = f.find(host, configured)
+		})
+		return creds, err
+	}
+}
+
+func (f *credentialFlags) find(host string, configured credentials) (*credentials, error) {
+	uid, err := findCredential(f.uidFile, apiUIDEnv, apiUIDCredential, configured.uid)
+	if err != nil {
+		return nil, err
+	}
+	key, err := findCredential(f.keyFile, apiKeyEnv, apiKeyCredential, configured.key)
+	if err != nil {
+		return nil, err
+	}
+	if uid == "" || key == "" {
+		var login, password string
+		if login, password, err = netrcCredentials(host); err != nil {
+			return nil, err
+		}
+		if uid == "" {
+			uid = login
+		}
+		if key == "" {
+			key = password
+		}
+	}
+	if uid == "" {
+		return nil, fmt.Errorf("%s not set, use -api-uid-file, $%s or ~/.netrc", apiUIDEnv, credentialsDirEnv)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("%s not set, use -api-key-file, $%s or ~/.netrc", apiKeyEnv, credentialsDirEnv)
+	}
+	return &credentials{uid: uid, key: key}, nil
+}
+
+func findCredential(path, env, name, configured string) (string, error) {
+	if path != "" {
+		return readCredentialFile(path)
+	}
+	if value := os.Getenv(env); value != "" {
+		return value, nil
+	}
+	if dir := os.Getenv(credentialsDirEnv); dir != "" {
+		value, err := readCredentialFile(filepath.Join(dir, name))
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return value, err
+		}
+	}
+	return configured, nil
+}
+
+// checkPermissions warns if the file is readable by everyone.
+func checkPermissions(file *os.File) {
+	if info, err := file.Stat(); err != nil {
+		log.Print(err)
+	} else if info.Mode().Perm()&worldReadableBits != 0 {
+		log.Printf("Warning: %s is readable by everyone, consider chmod o-r", file.Name())
+	}
+}
+
+func readCredentialFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	checkPermissions(file)
+	bs, err := io.ReadAll(io.LimitReader(file, maxCredentialLength))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bs)), nil
+}
+
+func netrcPath() string {
+	if path := os.Getenv(netrcEnv); path != "" {
+		return path
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".netrc")
+	}
+	return ""
+}
+
+// netrcCredentials returns the login and password for host from the
+// netrc file, or empty strings if there is none.
+func netrcCredentials(host string) (login, password string, err error) {
+	path := netrcPath()
+	if path == "" {
+		return "", "", nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+	checkPermissions(file)
+	login, password, err = parseNetrc(file, host)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", path, err)
+	}
+	return login, password, nil
+}
+
+// parseNetrc finds the login and password of the machine entry for
+// host, falling back to the default entry.
+func parseNetrc(r io.Reader, host string) (login, password string, err error) {
+	type entry struct{ login, password string }
+	var (
+		found, fallback *entry
+		current         *entry
+	)
+	scanner := bufio.NewScanner(r)
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			var value string
+			switch fields[i] {
+			case "machine", "login", "password", "account", "macdef":
+				if i+1 >= len(fields) {
+					return "", "", fmt.Errorf("missing value for %s", fields[i])
+				}
+				value = fields[i+1]
+			}
+			switch fields[i] {
+			case "machine":
+				current = &entry{}
+				if value == host && found == nil {
+					found = current
+				}
+			case "default":
+				current = &entry{}
+				if fallback == nil {
+					fallback = current
+				}
+			case "login":
+				if current != nil {
+					current.login = value
+				}
+			case "password":
+				if current != nil {
+					current.password = value
+				}
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+				continue
+			}
+			if value != "" {
+				i++
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", "", err
+	}
+	if found == nil {
+		found = fallback
+	}
+	if found == nil {
+		return "", "", nil
+	}
+	return found.login, found.password, nil
+}
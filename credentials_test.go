@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolateCredentials clears all credential sources from the test
+// environment.
+func isolateCredentials(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv(apiUIDEnv, "")
+	t.Setenv(apiKeyEnv, "")
+	t.Setenv(credentialsDirEnv, "")
+	t.Setenv(netrcEnv, filepath.Join(dir, "netrc"))
+	return dir
+}
+
+func writeSecret(t *testing.T, path, content string, perm os.FileMode) string {
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestCredentialFiles(t *testing.T) {
+	dir := isolateCredentials(t)
+	t.Setenv(apiUIDEnv, "env-uid")
+	flags := &credentialFlags{
+		uidFile: writeSecret(t, filepath.Join(dir, "uid"), "file-uid\n", 0o600),
+		keyFile: writeSecret(t, filepath.Join(dir, "key"), "file-key\n", 0o600),
+	}
+	creds, err := flags.lookup(apiHost, credentials{})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *creds != (credentials{uid: "file-uid", key: "file-key"}) {
+		t.Fatalf("Unexpected credentials %+v", *creds)
+	}
+}
+
+func TestCredentialsPrecedence(t *testing.T) {
+	dir := isolateCredentials(t)
+	t.Setenv(apiUIDEnv, "env-uid")
+	t.Setenv(credentialsDirEnv, dir)
+	writeSecret(t, filepath.Join(dir, apiUIDCredential), "systemd-uid", 0o400)
+	writeSecret(t, filepath.Join(dir, apiKeyCredential), "systemd-key", 0o400)
+	creds, err := (&credentialFlags{}).lookup(apiHost, credentials{uid: "config-uid", key: "config-key"})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *creds != (credentials{uid: "env-uid", key: "systemd-key"}) {
+		t.Fatalf("Unexpected credentials %+v", *creds)
+	}
+}
+
+func TestCredentialsNetrc(t *testing.T) {
+	dir := isolateCredentials(t)
+	writeSecret(t, filepath.Join(dir, "netrc"), `machine example.com login other password other
+machine api.bring.com
+  login netrc-uid
+  password netrc-key
+default login default password default
+`, 0o600)
+	creds, err := (&credentialFlags{}).lookup(apiHost, credentials{uid: "config-uid"})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *creds != (credentials{uid: "config-uid", key: "netrc-key"}) {
+		t.Fatalf("Unexpected credentials %+v", *creds)
+	}
+}
+
+func TestCredentialsNetrcHost(t *testing.T) {
+	dir := isolateCredentials(t)
+	writeSecret(t, filepath.Join(dir, "netrc"), `machine api.bring.com login bring-uid password bring-key
+machine localhost login local-uid password local-key
+`, 0o600)
+	creds, err := (&credentialFlags{}).lookup("localhost", credentials{})()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *creds != (credentials{uid: "local-uid", key: "local-key"}) {
+		t.Fatalf("Unexpected credentials %+v", *creds)
+	}
+}
+
+func TestCredentialsMissing(t *testing.T) {
+	isolateCredentials(t)
+	_, err := (&credentialFlags{}).lookup(apiHost, credentials{})()
+	if err == nil || !strings.Contains(err.Error(), apiUIDEnv) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	_, err = (&credentialFlags{}).lookup(apiHost, credentials{uid: "uid"})()
+	if err == nil || !strings.Contains(err.Error(), apiKeyEnv) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestCredentialFileMissing(t *testing.T) {
+	dir := isolateCredentials(t)
+	flags := &credentialFlags{uidFile: filepath.Join(dir, "missing")}
+	if _, err := flags.lookup(apiHost, credentials{})(); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestCredentialFileWorldReadable(t *testing.T) {
+	dir := isolateCredentials(t)
+	logged := captureLog(t)
+	flags := &credentialFlags{
+		uidFile: writeSecret(t, filepath.Join(dir, "uid"), "uid", 0o644),
+		keyFile: writeSecret(t, filepath.Join(dir, "key"), "key", 0o600),
+	}
+	if _, err := flags.lookup(apiHost, credentials{})(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged.String(), "uid is readable by everyone") {
+		t.Fatalf("Expected warning, got %q", logged.String())
+	}
+	if strings.Contains(logged.String(), "key is readable") {
+		t.Fatalf("Unexpected warning %q", logged.String())
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	for content, expected := range map[string]credentials{
+		"machine api.bring.com login a password b":                                               {uid: "a", key: "b"},
+		"default login d password e\nmachine api.bring.com login a password b":                   {uid: "a", key: "b"},
+		"machine other login a password b\ndefault login d password e":                           {uid: "d", key: "e"},
+		"macdef init\nmachine api.bring.com login x\n\nmachine api.bring.com login a password b": {uid: "a", key: "b"},
+		"machine other login a password b":                                                       {},
+	} {
+		login, password, err := parseNetrc(strings.NewReader(content), apiHost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := (credentials{uid: login, key: password}); got != expected {
+			t.Fatalf("%q: %+v != %+v", content, got, expected)
+		}
+	}
+	if _, _, err := parseNetrc(strings.NewReader("machine api.bring.com login"), apiHost); err == nil {
+		t.Fatal("Expected error")
+	}
+}
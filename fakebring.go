@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	deliveryDatesPrefix = "/address/api/no/postal-codes/"
+	deliveryDatesSuffix = "/mailbox-delivery-dates"
+	defaultScheduleDays = 14
+	defaultSlowDelay    = 5 * time.Second
+	faultSlow           = "slow"
+	faultMalformed      = "malformed"
+)
+
+// scheduleEpoch is a Monday. Weekdays are counted from it so that a
+// postal code keeps the same rhythm from one day to the next.
+var scheduleEpoch = time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC)
+
+// faultsT maps postal codes to the fault to inject. The empty code
+// applies to every postal code without a fault of its own.
+type faultsT map[string]string
+
+func (f faultsT) String() string {
+	items := make([]string, 0, len(f))
+	for code, kind := range f {
+		if code == "" {
+			items = append(items, kind)
+		} else {
+			items = append(items, code+"="+kind)
+		}
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// Set parses [CODE=]KIND, where KIND is slow, malformed, 401, 404,
+// 429 or a 5xx status code.
+func (f faultsT) Set(value string) error {
+	code, kind, found := strings.Cut(value, "=")
+	if !found {
+		code, kind = "", value
+	} else if c, err := toPostalCode(code); err != nil {
+		return err
+	} else {
+		code = c.code
+	}
+	if kind != faultSlow && kind != faultMalformed {
+		status, err := strconv.Atoi(kind)
+		if err != nil || !(status == http.StatusUnauthorized || status == http.StatusNotFound ||
+			status == http.StatusTooManyRequests || status >= 500 && status <= 599) {
+			return fmt.Errorf("unknown fault: %s", kind)
+		}
+	}
+	f[code] = kind
+	return nil
+}
+
+func (f faultsT) lookup(code string) string {
+	if kind, ok := f[code]; ok {
+		return kind
+	}
+	return f[""]
+}
+
+// fakeBringHandler is a local stand-in for the Bring mailbox delivery
+// dates API.
+type fakeBringHandler struct {
+	// fixtures is a directory with a {code}.json response per postal
+	// code. Without it a schedule is generated.
+	fixtures string
+	uid      string
+	key      string
+	days     int
+	faults   faultsT
+	delay    time.Duration
+	now      func() time.Time
+}
+
+func (h *fakeBringHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, deliveryDatesPrefix)
+	if name == r.URL.Path || !strings.HasSuffix(name, deliveryDatesSuffix) {
+		http.NotFound(w, r)
+		return
+	}
+	code, err := toPostalCode(strings.TrimSuffix(name, deliveryDatesSuffix))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !h.authorized(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	switch kind := h.faults.lookup(code.code); kind {
+	case "":
+	case faultSlow:
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(h.delay):
+		}
+	case faultMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"delivery_dates": ["2021-12-28",`)
+		return
+	default:
+		status, _ := strconv.Atoi(kind)
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	body, err := h.response(code)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, fmt.Sprintf("unknown postal code: %s", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%s: %s", code, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err = w.Write(body); err != nil {
+		log.Printf("%s: %s", code, err)
+	}
+}
+
+// authorized checks that both credential headers are present, and
+// that they match the expected values when those are set.
+func (h *fakeBringHandler) authorized(r *http.Request) bool {
+	uid, key := r.Header.Get(apiUIDHeader), r.Header.Get(apiKeyHeader)
+	if uid == "" || key == "" {
+		return false
+	}
+	return (h.uid == "" || uid == h.uid) && (h.key == "" || key == h.key)
+}
+
+func (h *fakeBringHandler) response(code *postalCodeT) ([]byte, error) {
+	if h.fixtures != "" {
+		return os.ReadFile(filepath.Join(h.fixtures, code.code+".json"))
+	}
+	return json.Marshal(map[string][]string{
+		"delivery_dates": generateSchedule(h.now().In(timezone), code, h.days),
+	})
+}
+
+// generateSchedule returns the delivery dates within days from now.
+// Mail is delivered every other weekday, and neighbouring postal codes
+// get alternate days.
+func generateSchedule(now time.Time, code *postalCodeT, days int) []string {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	n, _ := strconv.Atoi(code.code)
+	dates := []string{}
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		if (weekdaysSince(scheduleEpoch, day)+n)%2 == 0 {
+			dates = append(dates, day.Format(time.DateOnly))
+		}
+	}
+	return dates
+}
+
+// weekdaysSince counts the weekdays from epoch, a Monday, up to day.
+func weekdaysSince(epoch, day time.Time) int {
+	days := int(day.Sub(epoch).Hours() / 24)
+	rest := days % 7
+	if rest > 5 {
+		rest = 5
+	}
+	return days/7*5 + rest
+}
+
+type fakeBringArgs struct {
+	listen  string
+	handler *fakeBringHandler
+}
+
+func parseFakeBringArgs(cmd *flag.FlagSet, a []string) (fakeBringArgs, error) {
+	handler := &fakeBringHandler{faults: faultsT{}, now: time.Now}
+	var listenArg string
+	cmd.StringVar(&listenArg, "listen", "localhost:8081", "Listen on `address`")
+	cmd.StringVar(&handler.fixtures, "fixtures", "", "Serve {code}.json from `directory` instead of a generated schedule")
+	cmd.StringVar(&handler.uid, "api-uid", "", "Only accept requests with this API `uid`")
+	cmd.StringVar(&handler.key, "api-key", "", "Only accept requests with this API `key`")
+	cmd.IntVar(&handler.days, "days", defaultScheduleDays, "Generate a schedule for this many `days`")
+	cmd.Var(handler.faults, "fault", "Inject `[code=]fault`: slow, malformed, 401, 404, 429 or 5xx; may be repeated")
+	cmd.DurationVar(&handler.delay, "delay", defaultSlowDelay, "Delay slow responses by `duration`")
+	if err := cmd.Parse(a); err != nil {
+		return fakeBringArgs{}, err
+	}
+	if cmd.NArg() > 0 {
+		return fakeBringArgs{}, fmt.Errorf("unexpected arguments: %s", strings.Join(cmd.Args(), " "))
+	}
+	if handler.days < 1 {
+		return fakeBringArgs{}, fmt.Errorf("days must be at least 1, got %d", handler.days)
+	}
+	if handler.fixtures != "" {
+		if info, err := os.Stat(handler.fixtures); err != nil {
+			return fakeBringArgs{}, err
+		} else if !info.IsDir() {
+			return fakeBringArgs{}, fmt.Errorf("not a directory: %s", handler.fixtures)
+		}
+	}
+	return fakeBringArgs{listen: listenArg, handler: handler}, nil
+}
+
+func fakeBringCli(ctx context.Context, as []string) {
+	args, err := parseFakeBringArgs(flag.NewFlagSet("fake-bring", flag.ExitOnError), as)
+	if err != nil {
+		die(err)
+	}
+	if err = listenAndServe(ctx, args.listen, args.handler); err != nil {
+		die(err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fakeBringFixture(t *testing.T, handler *fakeBringHandler) deliveryProvider {
+	if handler.faults == nil {
+		handler.faults = faultsT{}
+	}
+	if handler.now == nil {
+		handler.now = func() time.Time { return *now() }
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	base, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := newProvider(bringProviderName, &providerOptions{
+		client:  server.Client(),
+		baseURL: base,
+		credentials: func() (*credentials, error) {
+			return &credentials{uid: "uid", key: "key"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestFakeBringFixtures(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "6666.json"), readFixture("test/fixture.json", t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider := fakeBringFixture(t, &fakeBringHandler{fixtures: dir, uid: "uid", key: "key"})
+	got, _, err := provider.fetch(context.Background(), postalCode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, dataFixture(t)) {
+		t.Fatalf("\n%+v\n\n!=\n\n%+v", got, dataFixture(t))
+	}
+	other, _ := toPostalCode("1234")
+	if _, _, err = provider.fetch(context.Background(), other); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Expected 404, got %v", err)
+	}
+}
+
+func TestFakeBringSchedule(t *testing.T) {
+	provider := fakeBringFixture(t, &fakeBringHandler{days: 14})
+	got, _, err := provider.fetch(context.Background(), postalCode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dates []string
+	for _, date := range got.DeliveryDates {
+		dates = append(dates, date.time.Format(time.DateOnly))
+	}
+	expected := []string{"2021-12-28", "2021-12-30", "2022-01-03", "2022-01-05", "2022-01-07"}
+	if !reflect.DeepEqual(dates, expected) {
+		t.Fatalf("%v != %v", dates, expected)
+	}
+}
+
+func TestGenerateScheduleAlternates(t *testing.T) {
+	even, _ := toPostalCode("6666")
+	odd, _ := toPostalCode("6667")
+	first, second := generateSchedule(*now(), even, 7), generateSchedule(*now(), odd, 7)
+	if len(first)+len(second) != 5 {
+		t.Fatalf("Expected every weekday to be covered once, got %v and %v", first, second)
+	}
+	for _, date := range first {
+		for _, other := range second {
+			if date == other {
+				t.Fatalf("%s delivered to both codes", date)
+			}
+		}
+	}
+}
+
+func TestFakeBringUnauthorized(t *testing.T) {
+	server := httptest.NewServer(&fakeBringHandler{uid: "uid", key: "key", days: 1, faults: faultsT{}, now: time.Now})
+	t.Cleanup(server.Close)
+	for _, header := range []map[string]string{
+		{},
+		{apiUIDHeader: "uid"},
+		{apiUIDHeader: "uid", apiKeyHeader: "wrong"},
+	} {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/address/api/no/postal-codes/6666/mailbox-delivery-dates", http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("%v: expected %d, got %d", header, http.StatusUnauthorized, resp.StatusCode)
+		}
+	}
+}
+
+func TestFakeBringFaults(t *testing.T) {
+	for fault, expected := range map[string]string{
+		"401":       "401 Unauthorized",
+		"404":       "404 Not Found",
+		"429":       "429 Too Many Requests",
+		"503":       "503 Service Unavailable",
+		"malformed": "unable to parse JSON",
+	} {
+		faults := faultsT{}
+		if err := faults.Set("6666=" + fault); err != nil {
+			t.Fatal(err)
+		}
+		provider := fakeBringFixture(t, &fakeBringHandler{days: 7, faults: faults})
+		if _, _, err := provider.fetch(context.Background(), postalCode()); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected %q, got %v", fault, expected, err)
+		}
+		other, _ := toPostalCode("1234")
+		if _, _, err := provider.fetch(context.Background(), other); err != nil {
+			t.Fatalf("%s: fault applied to %s: %v", fault, other, err)
+		}
+	}
+}
+
+func TestFakeBringSlow(t *testing.T) {
+	provider := fakeBringFixture(t, &fakeBringHandler{days: 7, faults: faultsT{"": faultSlow}, delay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := provider.fetch(ctx, postalCode()); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestParseFakeBringArgs(t *testing.T) {
+	got, err := parseFakeBringArgs(commandLine(), []string{"--fault=429", "--fault", "1234=slow", "--days=3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := got.handler.faults.String(); s != "1234=slow,429" {
+		t.Fatalf("Unexpected faults %s", s)
+	}
+	for _, args := range [][]string{
+		{"--fault=418"},
+		{"--fault=99999=slow"},
+		{"--days=0"},
+		{"--fixtures", filepath.Join(t.TempDir(), "missing")},
+	} {
+		if _, err = parseFakeBringArgs(commandLine(), args); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
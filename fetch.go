@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultConcurrency = 4
+
+// fetchResult is the outcome of fetching one postal code.
+type fetchResult struct {
+	response *postenResponseT
+	now      *time.Time
+	err      error
+}
+
+// rateLimiter lets through at most rate calls to wait per second, the
+// first one at once. A nil limiter or a zero rate means no limit.
+type rateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil || l.interval == 0 {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	if at == now {
+		return ctx.Err()
+	}
+	return sleepContext(ctx, at.Sub(now))
+}
+
+// fetchAll fetches the postal codes with at most concurrency fetches
+// running at once, started as fast as limiter allows. The results are
+// in the order of codes, failures are also joined in the returned
+// error. Codes not fetched when ctx is done fail with its error.
+func fetchAll(
+	ctx context.Context, provider deliveryProvider, codes []*postalCodeT, concurrency int, limiter *rateLimiter,
+) ([]*fetchResult, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]*fetchResult, len(codes))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(codes); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var response *postenResponseT
+				var now *time.Time
+				err := limiter.wait(ctx)
+				if err == nil {
+					response, now, err = provider.fetch(ctx, codes[i])
+				}
+				if err == nil && len(response.DeliveryDates) == 0 {
+					err = fmt.Errorf("no delivery days found, check postal code")
+				}
+				if err != nil {
+					err = fmt.Errorf("%s: %w", codes[i], err)
+				}
+				results[i] = &fetchResult{response: response, now: now, err: err}
+			}
+		}()
+	}
+	for i := range codes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	errs := make([]error, 0, len(results))
+	for _, result := range results {
+		if result.err != nil {
+			errs = append(errs, result.err)
+		}
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testCodes(t *testing.T, codes ...string) []*postalCodeT {
+	buf := make([]*postalCodeT, len(codes))
+	for i, c := range codes {
+		code, err := toPostalCode(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf[i] = code
+	}
+	return buf
+}
+
+type countingProvider struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+	fail     map[string]bool
+}
+
+func (p *countingProvider) fetch(_ context.Context, code *postalCodeT) (*postenResponseT, *time.Time, error) {
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.max {
+		p.max = p.inFlight
+	}
+	p.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+	if p.fail[code.code] {
+		return nil, nil, errors.New("failed")
+	}
+	n := time.Date(2021, 12, 28, 0, 0, 0, 0, time.UTC)
+	return &postenResponseT{DeliveryDates: []*CivilTime{{time: &n}}}, &n, nil
+}
+
+func TestFetchAllConcurrency(t *testing.T) {
+	provider := &countingProvider{}
+	codes := testCodes(t, "1", "2", "3", "4", "5", "6", "7", "8")
+	results, err := fetchAll(context.Background(), provider, codes, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(codes) {
+		t.Fatalf("Expected %d results, got %d", len(codes), len(results))
+	}
+	if provider.max > 3 || provider.max < 2 {
+		t.Fatalf("Expected at most 3 concurrent fetches, got %d", provider.max)
+	}
+}
+
+func TestFetchAllErrors(t *testing.T) {
+	provider := &countingProvider{fail: map[string]bool{"0002": true, "0004": true}}
+	codes := testCodes(t, "1", "2", "3", "4")
+	results, err := fetchAll(context.Background(), provider, codes, 2, nil)
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	for _, expected := range []string{"0002: failed", "0004: failed"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected %q in %q", expected, err)
+		}
+	}
+	if results[0].err != nil || results[1].err == nil || results[2].err != nil {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+}
+
+func TestFetchAllNoDeliveryDays(t *testing.T) {
+	provider := deliveryProviderFunc(func(context.Context, *postalCodeT) (*postenResponseT, *time.Time, error) {
+		return &postenResponseT{}, now(), nil
+	})
+	_, err := fetchAll(context.Background(), provider, testCodes(t, "6666"), 1, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "6666: no delivery days found") {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestFetchAllRate(t *testing.T) {
+	provider := &countingProvider{}
+	start := time.Now()
+	if _, err := fetchAll(context.Background(), provider, testCodes(t, "1", "2", "3", "4", "5"), 5, newRateLimiter(50)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Fatalf("Expected at least 80ms at 50 requests per second, took %s", elapsed)
+	}
+}
+
+func TestRateSharedByJobs(t *testing.T) {
+	args, err := parseArgs(commandLine(), []string{"--config", writeConfig(t, configFixture), "--rate=20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for _, job := range args.jobs {
+		if _, err = args.fetchCalendars(context.Background(), job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Four postal codes in three jobs.
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Fatalf("Expected at least 150ms at 20 fetches per second, took %s", elapsed)
+	}
+}
+
+func TestRateLimiterFirstAtOnce(t *testing.T) {
+	limiter := newRateLimiter(0.1)
+	start := time.Now()
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("First wait took %s", elapsed)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected second wait to time out, got %v", err)
+	}
+}
+
+func TestParseArgsConcurrency(t *testing.T) {
+	for _, args := range [][]string{
+		{"--code=6666", "--concurrency=0"},
+		{"--code=6666", "--rate=-1"},
+	} {
+		if _, err := parseArgs(commandLine(), args); err == nil {
+			t.Fatalf("Expected error for %s", strings.Join(args, " "))
+		}
+	}
+}
+
+func TestFetchAllCanceled(t *testing.T) {
+	provider := &countingProvider{}
+	results, err := fetchAll(canceledContext(), provider, testCodes(t, "1", "2"), 1, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if provider.max != 0 || results[0].err == nil {
+		t.Fatalf("Expected no fetches, got %d", provider.max)
+	}
+}
+
+func TestFetchAllTimeout(t *testing.T) {
+	provider := deliveryProviderFunc(func(ctx context.Context, _ *postalCodeT) (*postenResponseT, *time.Time, error) {
+		<-ctx.Done()
+		return nil, nil, ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := fetchAll(ctx, provider, testCodes(t, "1"), 1, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
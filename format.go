@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/taasan/postgang/ical"
+)
+
+const (
+	formatICS  = "ics"
+	formatJCal = "jcal"
+	formatXCal = "xcal"
+)
+
+// formats write a calendar in an output format.
+var formats = map[string]func(ctx context.Context, wr *bufio.Writer, cal *ical.Section) error{
+	formatICS: func(ctx context.Context, wr *bufio.Writer, cal *ical.Section) error {
+		return ical.NewContentPrinter(wr).PrintContext(ctx, cal).Error()
+	},
+	formatJCal: func(ctx context.Context, wr *bufio.Writer, cal *ical.Section) error {
+		return ical.NewJCalPrinter(wr).PrintContext(ctx, cal).Error()
+	},
+	formatXCal: func(ctx context.Context, wr *bufio.Writer, cal *ical.Section) error {
+		return ical.NewXCalPrinter(wr).PrintContext(ctx, cal).Error()
+	},
+}
+
+func formatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkFormat(name string) error {
+	if _, ok := formats[name]; !ok {
+		return fmt.Errorf("unknown format %q, expected one of: %s", name, strings.Join(formatNames(), ", "))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunJobJCal(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "6666.json")
+	args, err := parseArgs(commandLine(), []string{"--code=6666", "--provider=static", "--format=jcal", "--output", output, "--hostname=test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = args.runJob(context.Background(), args.jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []any
+	if err = json.Unmarshal(bs, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != "vcalendar" {
+		t.Fatalf("Unexpected component %v", got[0])
+	}
+	if events := got[2].([]any); len(events) == 0 {
+		t.Fatal("No events")
+	}
+}
+
+func TestRunJobXCal(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "6666.xml")
+	args, err := parseArgs(commandLine(), []string{"--code=6666", "--provider=static", "--format=xcal", "--output", output, "--hostname=test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = args.runJob(context.Background(), args.jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		XMLName  xml.Name
+		Calendar struct {
+			Events []struct{} `xml:"components>vevent"`
+		} `xml:"vcalendar"`
+	}
+	if err = xml.Unmarshal(bs, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.XMLName.Local != "icalendar" {
+		t.Fatalf("Unexpected root %v", got.XMLName)
+	}
+	if len(got.Calendar.Events) == 0 {
+		t.Fatal("No events")
+	}
+}
+
+func TestParseArgsUnknownFormat(t *testing.T) {
+	if _, err := parseArgs(commandLine(), []string{"--code=6666", "--format=csv"}); err == nil {
+		t.Fatal("Expected error")
+	}
+}
@@ -0,0 +1,127 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+var durationUnits = map[byte]time.Duration{
+	'W': week,
+	'D': day,
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+}
+
+// FormatDuration formats d as an RFC 5545 DURATION value, like -PT12H
+// or P1W. Fractions of a second are dropped.
+func FormatDuration(d time.Duration) string {
+	var sb strings.Builder
+	if d < 0 {
+		sb.WriteByte('-')
+		d = -d
+	}
+	d = d.Truncate(time.Second)
+	sb.WriteByte('P')
+	switch {
+	case d == 0:
+		sb.WriteString("T0S")
+		return sb.String()
+	case d%week == 0:
+		fmt.Fprintf(&sb, "%dW", d/week)
+		return sb.String()
+	case d >= day:
+		fmt.Fprintf(&sb, "%dD", d/day)
+		d %= day
+	}
+	if d == 0 {
+		return sb.String()
+	}
+	sb.WriteByte('T')
+	parts := []struct {
+		n    time.Duration
+		unit byte
+	}{
+		{d / time.Hour, 'H'},
+		{d % time.Hour / time.Minute, 'M'},
+		{d % time.Minute / time.Second, 'S'},
+	}
+	// Hours, minutes and seconds must be contiguous, so zeroes between
+	// the first and last part are kept.
+	first, last := -1, -1
+	for i, part := range parts {
+		if part.n != 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	for _, part := range parts[first : last+1] {
+		fmt.Fprintf(&sb, "%d%c", part.n, part.unit)
+	}
+	return sb.String()
+}
+
+// ParseDuration parses an RFC 5545 DURATION value.
+func ParseDuration(s string) (time.Duration, error) {
+	rest := s
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(rest, "-"):
+		sign, rest = -1, rest[1:]
+	case strings.HasPrefix(rest, "+"):
+		rest = rest[1:]
+	}
+	if !strings.HasPrefix(rest, "P") {
+		return 0, fmt.Errorf("invalid duration %q: missing P", s)
+	}
+	rest = rest[1:]
+	var (
+		d      time.Duration
+		inTime bool
+		weeks  bool
+		units  = "WD"
+		parts  int
+	)
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime || weeks {
+				return 0, fmt.Errorf("invalid duration %q: unexpected T", s)
+			}
+			inTime, units, rest = true, "HMS", rest[1:]
+			continue
+		}
+		i := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid duration %q: expected number", s)
+		}
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		unit := strings.IndexByte(units, rest[i])
+		if unit < 0 {
+			return 0, fmt.Errorf("invalid duration %q: unexpected %q", s, rest[i])
+		}
+		d += time.Duration(n) * durationUnits[rest[i]]
+		// Units come in order, and weeks stand alone.
+		units = units[unit+1:]
+		if rest[i] == 'W' {
+			weeks, units = true, ""
+		}
+		parts++
+		rest = rest[i+1:]
+	}
+	if parts == 0 || inTime && strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return sign * d, nil
+}
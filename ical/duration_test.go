@@ -0,0 +1,56 @@
+package ical
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		0:                                   "PT0S",
+		-12 * time.Hour:                     "-PT12H",
+		7*time.Hour + 30*time.Minute:        "PT7H30M",
+		time.Hour + time.Second:             "PT1H0M1S",
+		90 * time.Second:                    "PT1M30S",
+		14 * day:                            "P2W",
+		day + 2*time.Hour:                   "P1DT2H",
+		-day:                                "-P1D",
+		time.Second + 500*time.Millisecond:  "PT1S",
+		8*day + time.Minute + 2*time.Second: "P8DT1M2S",
+	} {
+		if got := FormatDuration(d); got != expected {
+			t.Fatalf("%s: %s != %s", d, got, expected)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"PT0S":     0,
+		"-PT12H":   -12 * time.Hour,
+		"+PT7H30M": 7*time.Hour + 30*time.Minute,
+		"P2W":      14 * day,
+		"P1DT2H":   day + 2*time.Hour,
+		"PT15M":    15 * time.Minute,
+		"P1D":      day,
+	} {
+		got, err := ParseDuration(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("%s: %s != %s", s, got, expected)
+		}
+		if back, _ := ParseDuration(FormatDuration(got)); back != got {
+			t.Fatalf("%s does not round trip: %s", s, FormatDuration(got))
+		}
+	}
+}
+
+func TestParseDurationInvalid(t *testing.T) {
+	for _, s := range []string{"", "P", "PT", "12H", "P1H", "PT1D", "P1W2D", "P1WT1H", "PT1M1H", "P1DT", "PTT1H", "P-1D", "P12"} {
+		if d, err := ParseDuration(s); err == nil {
+			t.Fatalf("%q: expected error, got %s", s, d)
+		}
+	}
+}
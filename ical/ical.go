@@ -2,14 +2,24 @@ package ical
 
 import (
 	"net/url"
+	"strconv"
 	"time"
 )
 
 type VEvent struct {
-	uid     string
-	url     *url.URL
-	summary string
-	date    *time.Time
+	uid         string
+	url         *url.URL
+	summary     string
+	description string
+	location    string
+	date        *time.Time
+	// end is set for timed events, which start at date.
+	end    *time.Time
+	alarms []*VAlarm
+	// lastModified is set when the event has a revision.
+	lastModified *time.Time
+	sequence     int
+	cancelled    bool
 }
 
 func NewVEvent(uid string, u *url.URL, summary string, date *time.Time) *VEvent {
@@ -21,6 +31,74 @@ func NewVEvent(uid string, u *url.URL, summary string, date *time.Time) *VEvent
 	}
 }
 
+// SetDescription sets the DESCRIPTION of the event, omitted when
+// empty.
+func (event *VEvent) SetDescription(description string) *VEvent {
+	event.description = description
+	return event
+}
+
+// SetLocation sets the LOCATION of the event, omitted when empty.
+func (event *VEvent) SetLocation(location string) *VEvent {
+	event.location = location
+	return event
+}
+
+// SetTime makes the event a timed event from start to end. Times are
+// written with the TZID of their location, and the calendar gets a
+// matching VTIMEZONE.
+func (event *VEvent) SetTime(start, end time.Time) *VEvent {
+	event.date, event.end = &start, &end
+	return event
+}
+
+// AddAlarm adds VALARM components to the event.
+func (event *VEvent) AddAlarm(alarms ...*VAlarm) *VEvent {
+	event.alarms = append(event.alarms, alarms...)
+	return event
+}
+
+// SetRevision gives the event a SEQUENCE and LAST-MODIFIED, telling
+// clients which copy of the event is the latest.
+func (event *VEvent) SetRevision(sequence int, lastModified time.Time) *VEvent {
+	event.sequence, event.lastModified = sequence, &lastModified
+	return event
+}
+
+// Cancel marks the event as cancelled. Cancelled events have no
+// alarms.
+func (event *VEvent) Cancel() *VEvent {
+	event.cancelled = true
+	return event
+}
+
+// VAlarm is a DISPLAY alarm triggered relative to the start of the
+// event it belongs to.
+type VAlarm struct {
+	trigger     time.Duration
+	description string
+}
+
+func NewVAlarm(trigger time.Duration, description string) *VAlarm {
+	return &VAlarm{trigger: trigger, description: description}
+}
+
+func (alarm *VAlarm) Trigger() *icalField {
+	return field("TRIGGER", FormatDuration(alarm.trigger))
+}
+
+func (alarm *VAlarm) Description() *icalField {
+	return field("DESCRIPTION", alarm.description)
+}
+
+func (alarm *VAlarm) section() *Section {
+	return section("VALARM", &Fields{Fields: []*icalField{
+		field("ACTION", "DISPLAY"),
+		alarm.Trigger(),
+		alarm.Description(),
+	}})
+}
+
 type VCalendar struct {
 	prodID    string
 	events    []*VEvent
@@ -31,6 +109,17 @@ func NewVCalendar(prodID string, timestamp *time.Time, events ...*VEvent) *VCale
 	return &VCalendar{prodID: prodID, events: events, timestamp: timestamp}
 }
 
+// Events returns the events of the calendar.
+func (cal *VCalendar) Events() []*VEvent {
+	return cal.events
+}
+
+// Single returns a calendar with just event, keeping the PRODID and
+// timestamp of cal.
+func (cal *VCalendar) Single(event *VEvent) *VCalendar {
+	return NewVCalendar(cal.prodID, cal.timestamp, event)
+}
+
 type icalField struct {
 	name       string
 	attributes []*Attribute
@@ -89,17 +178,46 @@ func dateField(name string, value *time.Time) *icalField {
 	return field(name, value.Format("20060102"), dateAttribute())
 }
 
+// dateTimeField is a DATE-TIME in UTC, or with a TZID parameter when
+// value is in another location.
+func dateTimeField(name string, value *time.Time) *icalField {
+	if !hasTZID(value.Location()) {
+		return field(name, value.UTC().Format(utcDateTimeLayout))
+	}
+	return field(name, value.Format(localDateTimeLayout), &Attribute{Name: "TZID", Value: value.Location().String()})
+}
+
+// hasTZID reports whether times in loc are given with a TZID. UTC and
+// the unnamed local zone are written as UTC.
+func hasTZID(loc *time.Location) bool {
+	return loc != time.UTC && loc != time.Local && loc.String() != "UTC"
+}
+
 func (event *VEvent) DtStart() *icalField {
+	if event.end != nil {
+		return dateTimeField("DTSTART", event.date)
+	}
 	return dateField("DTSTART", event.date)
 }
 
 func (event *VEvent) DtEnd() *icalField {
+	if event.end != nil {
+		return dateTimeField("DTEND", event.end)
+	}
 	dtEnd := event.date.AddDate(0, 0, 1)
 	return dateField("DTEND", &dtEnd)
 }
 
+func (event *VEvent) Sequence() *icalField {
+	return field("SEQUENCE", strconv.Itoa(event.sequence))
+}
+
+func (event *VEvent) LastModified() *icalField {
+	return field("LAST-MODIFIED", event.lastModified.In(time.UTC).Format(utcDateTimeLayout))
+}
+
 func (cal *VCalendar) DtStamp() *icalField {
-	return field("DTSTAMP", cal.timestamp.In(time.UTC).Format("20060102T150405Z"))
+	return field("DTSTAMP", cal.timestamp.In(time.UTC).Format(utcDateTimeLayout))
 }
 
 func (cal *VCalendar) ProdID() *icalField {
@@ -118,12 +236,35 @@ func (event *VEvent) Summary() *icalField {
 	return field("SUMMARY", event.summary)
 }
 
+func (event *VEvent) Description() *icalField {
+	return field("DESCRIPTION", event.description)
+}
+
+func (event *VEvent) Location() *icalField {
+	return field("LOCATION", event.location)
+}
+
 func Calendar(cal *VCalendar) *Section {
+	return calendar(cal, true)
+}
+
+// CalendarObject returns the calendar as a CalDAV calendar object
+// resource, which must not have a METHOD (RFC 4791, section 4.1).
+func CalendarObject(cal *VCalendar) *Section {
+	return calendar(cal, false)
+}
+
+func calendar(cal *VCalendar, withMethod bool) *Section {
 	fields := []*icalField{
 		field("VERSION", "2.0"),
 		cal.ProdID(),
 		field("CALSCALE", "GREGORIAN"),
-		field("METHOD", "PUBLISH"),
+	}
+	if withMethod {
+		fields = append(fields, field("METHOD", "PUBLISH"))
+	}
+	for _, tz := range cal.timezones() {
+		fields = append(fields, tz.fields()...)
 	}
 	for _, x := range cal.events {
 		e := event(x, cal)
@@ -132,20 +273,76 @@ func Calendar(cal *VCalendar) *Section {
 	return section("VCALENDAR", &Fields{Fields: fields})
 }
 
+// timezones returns a VTIMEZONE for each location used by timed
+// events, covering the years of the events.
+func (cal *VCalendar) timezones() []*Section {
+	type span struct {
+		loc         *time.Location
+		first, last int
+	}
+	var spans []*span
+	byName := map[string]*span{}
+	for _, event := range cal.events {
+		if event.end == nil {
+			continue
+		}
+		for _, t := range []*time.Time{event.date, event.end} {
+			if !hasTZID(t.Location()) {
+				continue
+			}
+			s, ok := byName[t.Location().String()]
+			if !ok {
+				s = &span{loc: t.Location(), first: t.Year(), last: t.Year()}
+				byName[t.Location().String()] = s
+				spans = append(spans, s)
+			}
+			if t.Year() < s.first {
+				s.first = t.Year()
+			}
+			if t.Year() > s.last {
+				s.last = t.Year()
+			}
+		}
+	}
+	sections := make([]*Section, len(spans))
+	for i, s := range spans {
+		// The year before makes the observance in effect at the
+		// start of the first year part of the VTIMEZONE.
+		sections[i] = VTimezone(s.loc, s.first-1, s.last)
+	}
+	return sections
+}
+
 func event(event *VEvent, cal *VCalendar) *Section {
-	fields := &Fields{
-		Fields: []*icalField{
-			event.UID(),
-			event.URL(),
-			event.Summary(),
-			field("TRANSP", "TRANSPARENT"),
-			event.DtStart(),
-			event.DtEnd(),
-			cal.DtStamp(),
-		},
-	}
-
-	return section("VEVENT", fields)
+	fields := []*icalField{
+		event.UID(),
+		event.URL(),
+		event.Summary(),
+	}
+	if event.description != "" {
+		fields = append(fields, event.Description())
+	}
+	if event.location != "" {
+		fields = append(fields, event.Location())
+	}
+	fields = append(fields,
+		field("TRANSP", "TRANSPARENT"),
+		event.DtStart(),
+		event.DtEnd(),
+		cal.DtStamp(),
+	)
+	if event.lastModified != nil {
+		fields = append(fields, event.Sequence(), event.LastModified())
+	}
+	if event.cancelled {
+		fields = append(fields, field("STATUS", "CANCELLED"))
+	} else {
+		for _, alarm := range event.alarms {
+			fields = append(fields, alarm.section().fields()...)
+		}
+	}
+
+	return section("VEVENT", &Fields{Fields: fields})
 }
 
 func section(name string, content icalContent) *Section {
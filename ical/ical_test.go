@@ -2,6 +2,7 @@ package ical
 
 import (
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 )
@@ -46,3 +47,61 @@ func TestCalendar(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestCalendarObjectHasNoMethod(t *testing.T) {
+	got := CalendarObject(vcalFixture()).String()
+	if strings.Contains(got, "METHOD:") {
+		t.Fatalf("Unexpected METHOD in\n%s", got)
+	}
+	if n := len(CalendarObject(vcalFixture()).fields()); n != len(Calendar(vcalFixture()).fields())-1 {
+		t.Fatalf("Expected one field less than Calendar, got %d", n)
+	}
+}
+
+func TestEventRevision(t *testing.T) {
+	cal := vcalFixture()
+	modified := time.Date(2021, 12, 27, 10, 0, 0, 0, time.UTC)
+	cal.events[0].AddAlarm(NewVAlarm(-time.Hour, "Alarm")).SetRevision(2, modified)
+	got := event(cal.events[0], cal).String()
+	for _, expected := range []string{"SEQUENCE:2\r\n", "LAST-MODIFIED:20211227T100000Z\r\n", "BEGIN:VALARM\r\n"} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("Expected %q in\n%s", expected, got)
+		}
+	}
+	got = event(cal.events[0].Cancel(), cal).String()
+	if !strings.Contains(got, "STATUS:CANCELLED\r\n") || strings.Contains(got, "VALARM") {
+		t.Fatalf("Unexpected cancelled event\n%s", got)
+	}
+}
+
+func TestEventDescriptionAndLocation(t *testing.T) {
+	cal := vcalFixture()
+	got := len(event(cal.events[0], cal).fields())
+	cal.events[0].SetDescription("Description").SetLocation("Location")
+	if n := len(event(cal.events[0], cal).fields()); n != got+2 {
+		t.Fatalf("Expected %d fields, got %d", got+2, n)
+	}
+}
+
+func TestEventAlarms(t *testing.T) {
+	cal := vcalFixture()
+	cal.events[0].AddAlarm(NewVAlarm(7*time.Hour+30*time.Minute, "Morning"), NewVAlarm(-5*time.Hour, "Evening"))
+	got := event(cal.events[0], cal).String()
+	expected := strings.Join([]string{
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER:PT7H30M",
+		"DESCRIPTION:Morning",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER:-PT5H",
+		"DESCRIPTION:Evening",
+		"END:VALARM",
+		"END:VEVENT",
+		"",
+	}, "\r\n")
+	if !strings.HasSuffix(got, expected) {
+		t.Fatalf("\n%s\ndoes not end with\n%s", got, expected)
+	}
+}
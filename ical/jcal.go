@@ -0,0 +1,209 @@
+package ical
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// JCalPrinter writes content as RFC 7265 jCal, the JSON form of
+// iCalendar. It walks the same field stream as ContentPrinter.
+type JCalPrinter struct {
+	writer io.Writer
+	err    error
+}
+
+func NewJCalPrinter(wr io.Writer) *JCalPrinter {
+	return &JCalPrinter{writer: wr}
+}
+
+func (p *JCalPrinter) Error() error {
+	return p.err
+}
+
+// jcalComponent is a component as the jCal array of its name,
+// properties and subcomponents.
+type jcalComponent struct {
+	name       string
+	properties []any
+	components []any
+}
+
+func (c *jcalComponent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{c.name, c.properties, c.components})
+}
+
+func (p *JCalPrinter) Print(content icalContent) *JCalPrinter {
+	return p.PrintContext(context.Background(), content)
+}
+
+// PrintContext prints content, stopping with the error of ctx when it
+// is done. A single top level component is printed as one jCal array,
+// several as an array of them.
+func (p *JCalPrinter) PrintContext(ctx context.Context, content icalContent) *JCalPrinter {
+	if p.err != nil {
+		return p
+	}
+	sections, err := nest(ctx, content)
+	if p.err = err; err != nil {
+		return p
+	}
+	top := make([]any, len(sections))
+	for i, s := range sections {
+		top[i] = toJCalComponent(s)
+	}
+	var document any = top
+	if len(top) == 1 {
+		document = top[0]
+	}
+	encoder := json.NewEncoder(p.writer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	p.err = encoder.Encode(document)
+	return p
+}
+
+func toJCalComponent(s *Section) *jcalComponent {
+	c := &jcalComponent{name: strings.ToLower(s.name), properties: []any{}, components: []any{}}
+	for _, f := range s.Properties() {
+		c.properties = append(c.properties, jcalProperty(f))
+	}
+	for _, sub := range s.Components() {
+		c.components = append(c.components, toJCalComponent(sub))
+	}
+	return c
+}
+
+func jcalProperty(f *icalField) []any {
+	params := map[string]string{}
+	for _, a := range f.attributes {
+		if !strings.EqualFold(a.Name, "VALUE") {
+			params[strings.ToLower(a.Name)] = a.Value
+		}
+	}
+	valueType := f.ValueType()
+	typeName := strings.ToLower(string(valueType))
+	if !knownTypes[valueType] {
+		typeName = "unknown"
+	}
+	return []any{strings.ToLower(f.name), params, typeName, jcalValue(valueType, f.value)}
+}
+
+var knownTypes = map[ValueType]bool{
+	TypeBinary: true, TypeBoolean: true, TypeCalAddress: true, TypeDate: true,
+	TypeDateTime: true, TypeDuration: true, TypeFloat: true, TypeInteger: true,
+	TypePeriod: true, TypeRecur: true, TypeText: true, TypeTime: true,
+	TypeURI: true, TypeUTCOffset: true,
+}
+
+// jcalValue converts a value to its jCal form, leaving values that do
+// not parse as strings.
+func jcalValue(valueType ValueType, value string) any {
+	switch valueType {
+	case TypeDate:
+		return isoDate(value)
+	case TypeDateTime:
+		return isoDateTime(value)
+	case TypeTime:
+		return isoTime(value)
+	case TypePeriod:
+		start, end, _ := strings.Cut(value, "/")
+		if strings.HasPrefix(end, "P") || strings.HasPrefix(end, "-P") || strings.HasPrefix(end, "+P") {
+			return isoDateTime(start) + "/" + end
+		}
+		return isoDateTime(start) + "/" + isoDateTime(end)
+	case TypeUTCOffset:
+		return isoUTCOffset(value)
+	case TypeInteger:
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	case TypeFloat:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case TypeBoolean:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case TypeRecur:
+		return jcalRecur(value)
+	}
+	return value
+}
+
+func isoDate(value string) string {
+	if len(value) != 8 {
+		return value
+	}
+	return value[:4] + "-" + value[4:6] + "-" + value[6:]
+}
+
+func isoTime(value string) string {
+	if len(value) < 6 {
+		return value
+	}
+	return value[:2] + ":" + value[2:4] + ":" + value[4:]
+}
+
+func isoUTCOffset(value string) string {
+	if len(value) != 5 && len(value) != 7 {
+		return value
+	}
+	s := value[:3] + ":" + value[3:5]
+	if len(value) == 7 {
+		s += ":" + value[5:]
+	}
+	return s
+}
+
+func isoDateTime(value string) string {
+	date, t, found := strings.Cut(value, "T")
+	if !found {
+		return isoDate(value)
+	}
+	return isoDate(date) + "T" + isoTime(t)
+}
+
+// recurNumeric are the RECUR rule parts with integer values.
+var recurNumeric = map[string]bool{
+	"count": true, "interval": true, "bysecond": true, "byminute": true,
+	"byhour": true, "bymonthday": true, "byyearday": true, "byweekno": true,
+	"bymonth": true, "bysetpos": true,
+}
+
+// jcalRecur converts a RECUR value to a jCal object, with lists for
+// rule parts with several values.
+func jcalRecur(value string) any {
+	recur := map[string]any{}
+	for _, part := range strings.Split(value, ";") {
+		key, values, found := strings.Cut(part, "=")
+		if !found {
+			return value
+		}
+		key = strings.ToLower(key)
+		var items []any
+		for _, v := range strings.Split(values, ",") {
+			switch {
+			case key == "until":
+				items = append(items, isoDateTime(v))
+			case recurNumeric[key]:
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return value
+				}
+				items = append(items, n)
+			default:
+				items = append(items, v)
+			}
+		}
+		if len(items) == 1 {
+			recur[key] = items[0]
+		} else {
+			recur[key] = items
+		}
+	}
+	return recur
+}
@@ -0,0 +1,105 @@
+package ical
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func printJCal(t *testing.T, content icalContent) []any {
+	var sb strings.Builder
+	if err := NewJCalPrinter(&sb).Print(content).Error(); err != nil {
+		t.Fatal(err)
+	}
+	var got []any
+	if err := json.Unmarshal([]byte(sb.String()), &got); err != nil {
+		t.Fatalf("%s: %s", err, sb.String())
+	}
+	return got
+}
+
+// jcalProperties returns the properties of a jCal component by name.
+func jcalProperties(component []any) map[string][]any {
+	props := map[string][]any{}
+	for _, p := range component[1].([]any) {
+		prop := p.([]any)
+		props[prop[0].(string)] = prop
+	}
+	return props
+}
+
+func TestJCalCalendar(t *testing.T) {
+	cal := vcalFixture()
+	cal.events[0].AddAlarm(NewVAlarm(-time.Hour, "Alarm"))
+	got := printJCal(t, Calendar(cal))
+	if got[0] != "vcalendar" {
+		t.Fatalf("Unexpected component %v", got[0])
+	}
+	if props := jcalProperties(got); !reflect.DeepEqual(props["prodid"], []any{"prodid", map[string]any{}, "text", prodID()}) {
+		t.Fatalf("Unexpected PRODID %v", props["prodid"])
+	}
+	event := got[2].([]any)[0].([]any)
+	props := jcalProperties(event)
+	for name, expected := range map[string][]any{
+		"url":     {"url", map[string]any{}, "uri", "https://www.example.com"},
+		"dtstart": {"dtstart", map[string]any{}, "date", "2020-01-02"},
+		"dtstamp": {"dtstamp", map[string]any{}, "date-time", timestamp().UTC().Format("2006-01-02T15:04:05Z")},
+	} {
+		if !reflect.DeepEqual(props[name], expected) {
+			t.Fatalf("%v != %v", props[name], expected)
+		}
+	}
+	alarm := event[2].([]any)[0].([]any)
+	if trigger := jcalProperties(alarm)["trigger"]; !reflect.DeepEqual(trigger, []any{"trigger", map[string]any{}, "duration", "-PT1H"}) {
+		t.Fatalf("Unexpected TRIGGER %v", trigger)
+	}
+}
+
+func TestJCalTimezone(t *testing.T) {
+	got := printJCal(t, VTimezone(loadLocation(t, "Europe/Oslo"), 2021, 2021))
+	daylight := jcalProperties(got[2].([]any)[0].([]any))
+	for name, expected := range map[string][]any{
+		"dtstart":    {"dtstart", map[string]any{}, "date-time", "2021-03-28T02:00:00"},
+		"tzoffsetto": {"tzoffsetto", map[string]any{}, "utc-offset", "+02:00"},
+		"rrule":      {"rrule", map[string]any{}, "recur", map[string]any{"freq": "YEARLY", "bymonth": 3.0, "byday": "-1SU"}},
+	} {
+		if !reflect.DeepEqual(daylight[name], expected) {
+			t.Fatalf("%v != %v", daylight[name], expected)
+		}
+	}
+}
+
+func TestJCalValues(t *testing.T) {
+	for _, tc := range []struct {
+		f        *icalField
+		expected []any
+	}{
+		{field("DTSTART", "20211228T100000", &Attribute{Name: "TZID", Value: "Europe/Oslo"}),
+			[]any{"dtstart", map[string]any{"tzid": "Europe/Oslo"}, "date-time", "2021-12-28T10:00:00"}},
+		{field("SEQUENCE", "2"), []any{"sequence", map[string]any{}, "integer", 2.0}},
+		{field("RRULE", "FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20220101T000000Z"),
+			[]any{"rrule", map[string]any{}, "recur", map[string]any{
+				"freq": "WEEKLY", "byday": []any{"MO", "WE"}, "until": "2022-01-01T00:00:00Z",
+			}}},
+		{field("X-THING", "x", &Attribute{Name: "VALUE", Value: "X-NAME"}), []any{"x-thing", map[string]any{}, "unknown", "x"}},
+	} {
+		got := printJCal(t, section("VCALENDAR", &Fields{Fields: []*icalField{tc.f}}))
+		if props := jcalProperties(got); !reflect.DeepEqual(props[strings.ToLower(tc.f.name)], tc.expected) {
+			t.Fatalf("%v != %v", props[strings.ToLower(tc.f.name)], tc.expected)
+		}
+	}
+}
+
+func TestJCalPrintContextCanceled(t *testing.T) {
+	var sb strings.Builder
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := NewJCalPrinter(&sb).PrintContext(ctx, sectionFixture())
+	if !errors.Is(p.Error(), context.Canceled) || sb.String() != "" {
+		t.Fatalf("Unexpected error %v, output %q", p.Error(), sb.String())
+	}
+}
@@ -0,0 +1,228 @@
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Problem is a violation of RFC 5545 found by Lint.
+type Problem struct {
+	Line    int
+	Message string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+type linter struct {
+	problems []*Problem
+	fields   map[*icalField]int
+	sections map[*Section]int
+	uids     map[string]int
+}
+
+func (l *linter) report(line int, format string, a ...any) {
+	l.problems = append(l.problems, &Problem{Line: line, Message: fmt.Sprintf(format, a...)})
+}
+
+// Lint checks the RFC 5545 stream read from r, returning the problems
+// found sorted by line. The error is only set when reading fails.
+func Lint(r io.Reader) ([]*Problem, error) {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{fields: map[*icalField]int{}, sections: map[*Section]int{}, uids: map[string]int{}}
+	l.checkLines(bs)
+	if sections := l.parse(bs); sections != nil {
+		if len(sections) == 0 {
+			l.report(1, "no VCALENDAR found")
+		}
+		for _, s := range sections {
+			l.checkCalendar(s)
+		}
+	}
+	sort.SliceStable(l.problems, func(i, j int) bool { return l.problems[i].Line < l.problems[j].Line })
+	return l.problems, nil
+}
+
+// checkLines checks the physical lines: their endings and length.
+func (l *linter) checkLines(bs []byte) {
+	for i, line := range bytes.SplitAfter(bs, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		content := bytes.TrimSuffix(line, []byte("\n"))
+		switch {
+		case len(content) == len(line):
+			l.report(i+1, "missing CRLF at end of line")
+		case !bytes.HasSuffix(content, []byte("\r")):
+			l.report(i+1, "bare LF line ending")
+		default:
+			content = bytes.TrimSuffix(content, []byte("\r"))
+		}
+		if len(content) > maxLineLen {
+			l.report(i+1, "line is %d octets long, more than %d", len(content), maxLineLen)
+		}
+	}
+}
+
+// parse builds the component tree, remembering the line of every
+// property and component. It returns nil after a syntax error.
+func (l *linter) parse(bs []byte) []*Section {
+	p := NewParser(bytes.NewReader(bs))
+	var tree treeBuilder
+	for {
+		line, number, ok, err := p.contentLine()
+		if err != nil || !ok {
+			break
+		}
+		if line == "" {
+			l.report(number, "empty line")
+			continue
+		}
+		f, err := parseRawContentLine(line)
+		if err != nil {
+			l.report(number, "%s", err)
+			return nil
+		}
+		if f.ValueType() == TypeText {
+			if bad := badEscape(f.value); bad != "" {
+				l.report(number, "%s: invalid escape %q in TEXT value", f.name, bad)
+			}
+			f.value = unescapeText(f.value)
+		}
+		l.fields[f] = number
+		if err = tree.add(f); err != nil {
+			l.report(number, "%s", err)
+			return nil
+		}
+		if f.name == "BEGIN" {
+			l.sections[tree.stack[len(tree.stack)-1]] = number
+		}
+	}
+	items, err := tree.finish()
+	if err != nil {
+		l.report(p.line, "%s", err)
+		return nil
+	}
+	return toSections(items)
+}
+
+// badEscape returns the first backslash escape not allowed in TEXT.
+func badEscape(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			continue
+		}
+		if i+1 == len(s) {
+			return `\`
+		}
+		i++
+		if !strings.ContainsRune(`\;,nN`, rune(s[i])) {
+			return s[i-1 : i+1]
+		}
+	}
+	return ""
+}
+
+// requireOnce reports required properties that are missing or given
+// more than once.
+func (l *linter) requireOnce(s *Section, names ...string) {
+	for _, name := range names {
+		var found []*icalField
+		for _, f := range s.Properties() {
+			if f.name == name {
+				found = append(found, f)
+			}
+		}
+		switch {
+		case len(found) == 0:
+			l.report(l.sections[s], "%s: missing required property %s", s.name, name)
+		case len(found) > 1:
+			l.report(l.fields[found[1]], "%s: property %s given more than once", s.name, name)
+		}
+	}
+}
+
+func (l *linter) checkCalendar(cal *Section) {
+	if cal.name != "VCALENDAR" {
+		l.report(l.sections[cal], "expected VCALENDAR, got %s", cal.name)
+		return
+	}
+	l.requireOnce(cal, "PRODID", "VERSION")
+	if v := cal.Property("VERSION"); v != nil && v.value != "2.0" {
+		l.report(l.fields[v], "unsupported VERSION %s", v.value)
+	}
+	for _, s := range cal.Components() {
+		switch s.name {
+		case "VEVENT":
+			l.checkEvent(s)
+		case "VTIMEZONE":
+			l.requireOnce(s, "TZID")
+		}
+	}
+}
+
+func (l *linter) checkEvent(event *Section) {
+	l.requireOnce(event, "UID", "DTSTAMP", "DTSTART")
+	if uid := event.Property("UID"); uid != nil && event.Property("RECURRENCE-ID") == nil {
+		if first, ok := l.uids[uid.value]; ok {
+			l.report(l.fields[uid], "duplicate UID %s, first used on line %d", uid.value, first)
+		} else {
+			l.uids[uid.value] = l.fields[uid]
+		}
+	}
+	dtStart, dtEnd := event.Property("DTSTART"), event.Property("DTEND")
+	if dtEnd != nil && event.Property("DURATION") != nil {
+		l.report(l.fields[dtEnd], "VEVENT: both DTEND and DURATION given")
+	}
+	start, startErr := l.dateValue(dtStart)
+	end, endErr := l.dateValue(dtEnd)
+	if startErr == nil && endErr == nil && dtStart != nil && dtEnd != nil {
+		if dtStart.ValueType() != dtEnd.ValueType() {
+			l.report(l.fields[dtEnd], "DTEND is %s, DTSTART is %s", dtEnd.ValueType(), dtStart.ValueType())
+		} else if !start.Before(end) {
+			l.report(l.fields[dtEnd], "DTEND %s is not after DTSTART %s", dtEnd.value, dtStart.value)
+		}
+	}
+	for _, alarm := range event.Components("VALARM") {
+		l.requireOnce(alarm, "ACTION", "TRIGGER")
+		if action := alarm.Property("ACTION"); action != nil && action.value == "DISPLAY" {
+			l.requireOnce(alarm, "DESCRIPTION")
+		}
+	}
+}
+
+// dateValue parses a DATE or DATE-TIME property, reporting invalid
+// values. Missing properties give the zero time.
+func (l *linter) dateValue(f *icalField) (time.Time, error) {
+	if f == nil {
+		return time.Time{}, nil
+	}
+	loc := time.UTC
+	if tzid := f.Attribute("TZID"); tzid != nil {
+		var err error
+		if loc, err = time.LoadLocation(tzid.Value); err != nil {
+			l.report(l.fields[f], "%s: unknown TZID %s", f.name, tzid.Value)
+			return time.Time{}, err
+		}
+	}
+	layout := localDateTimeLayout
+	switch {
+	case f.ValueType() == TypeDate:
+		layout = "20060102"
+	case strings.HasSuffix(f.value, "Z"):
+		layout = utcDateTimeLayout
+	}
+	t, err := time.ParseInLocation(layout, f.value, loc)
+	if err != nil {
+		l.report(l.fields[f], "%s: invalid %s value %q", f.name, f.ValueType(), f.value)
+	}
+	return t, err
+}
@@ -0,0 +1,72 @@
+package ical
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func lint(t *testing.T, s string) []*Problem {
+	problems, err := Lint(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return problems
+}
+
+func TestLintGenerated(t *testing.T) {
+	cal := vcalFixture()
+	cal.events[0].SetDescription(fmt.Sprintf("Long, escaped; text\\ %0*d", 2*maxLineLen, 0)).
+		AddAlarm(NewVAlarm(-time.Hour, "Alarm"))
+	start := time.Date(2021, 12, 28, 10, 0, 0, 0, loadLocation(t, "Europe/Oslo"))
+	timed := NewVEvent("timed", cal.events[0].url, "Timed", &start).SetTime(start, start.Add(time.Hour))
+	cal.events = append(cal.events, timed)
+	if problems := lint(t, Calendar(cal).String()); len(problems) != 0 {
+		t.Fatalf("Unexpected problems %v", problems)
+	}
+}
+
+func TestLintProblems(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:a",
+		"SUMMARY:bad \\x escape",
+		"DTSTAMP:20211228T000000Z",
+		"DTSTART;VALUE=DATE:20211229",
+		"DTEND;VALUE=DATE:20211228",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:a",
+		"DTSTART:20211228T100000Z",
+		"END:VEVENT\nDESCRIPTION:" + strings.Repeat("x", maxLineLen),
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	var got []string
+	for _, p := range lint(t, input) {
+		got = append(got, p.String())
+	}
+	expected := []string{
+		"line 1: VCALENDAR: missing required property PRODID",
+		`line 5: SUMMARY: invalid escape "\\x" in TEXT value`,
+		"line 8: DTEND 20211228 is not after DTSTART 20211229",
+		"line 10: VEVENT: missing required property DTSTAMP",
+		"line 11: duplicate UID a, first used on line 4",
+		"line 13: bare LF line ending",
+		"line 14: line is 87 octets long, more than 75",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("\n%s\n!=\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	problems := lint(t, "BEGIN:VCALENDAR\r\nPRODID\r\nEND:VCALENDAR\r\n")
+	if len(problems) != 1 || problems[0].Line != 2 {
+		t.Fatalf("Unexpected problems %v", problems)
+	}
+}
@@ -0,0 +1,407 @@
+package ical
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseError is an error found on a line of the parsed stream.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Items is an ordered mix of properties and subcomponents, the
+// content of a parsed Section.
+type Items []icalContent
+
+func (items Items) fields() []*icalField {
+	var buf []*icalField
+	for _, item := range items {
+		buf = append(buf, item.fields()...)
+	}
+	return buf
+}
+
+func (f *icalField) fields() []*icalField {
+	return []*icalField{f}
+}
+
+// Parser reads RFC 5545 content lines. Folded lines are joined, and
+// lines may end with CRLF or a bare LF.
+type Parser struct {
+	reader *bufio.Reader
+	// line is the number of the last physical line read.
+	line int
+	// next is the first line of the next content line, read while
+	// looking for folded lines.
+	next    string
+	hasNext bool
+}
+
+func NewParser(r io.Reader) *Parser {
+	return &Parser{reader: bufio.NewReader(r)}
+}
+
+func (p *Parser) readLine() (string, bool, error) {
+	if p.hasNext {
+		p.hasNext = false
+		return p.next, true, nil
+	}
+	line, err := p.reader.ReadString('\n')
+	if err == io.EOF {
+		if line == "" {
+			return "", false, nil
+		}
+		err = nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	p.line++
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), true, nil
+}
+
+// contentLine returns the next unfolded content line and the number
+// of its first physical line.
+func (p *Parser) contentLine() (string, int, bool, error) {
+	line, ok, err := p.readLine()
+	if !ok || err != nil {
+		return "", 0, ok, err
+	}
+	start := p.line
+	var sb strings.Builder
+	sb.WriteString(line)
+	for {
+		next, ok, err := p.readLine()
+		if err != nil {
+			return "", 0, false, err
+		}
+		if !ok {
+			break
+		}
+		if next == "" || (next[0] != ' ' && next[0] != '\t') {
+			p.next, p.hasNext = next, true
+			break
+		}
+		sb.WriteString(next[1:])
+	}
+	return sb.String(), start, true, nil
+}
+
+// Parse reads the whole stream and returns its top level components,
+// usually a single VCALENDAR.
+func (p *Parser) Parse() ([]*Section, error) {
+	var tree treeBuilder
+	for {
+		line, number, ok, err := p.contentLine()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if line == "" {
+			continue
+		}
+		f, err := parseContentLine(line)
+		if err == nil {
+			err = tree.add(f)
+		}
+		if err != nil {
+			return nil, &ParseError{Line: number, Err: err}
+		}
+	}
+	items, err := tree.finish()
+	if err != nil {
+		return nil, &ParseError{Line: p.line, Err: err}
+	}
+	return toSections(items), nil
+}
+
+// Parse parses the RFC 5545 stream read from r.
+func Parse(r io.Reader) ([]*Section, error) {
+	return NewParser(r).Parse()
+}
+
+// nest returns the top level components of the field stream of
+// content, stopping with the error of ctx when it is done.
+func nest(ctx context.Context, content icalContent) ([]*Section, error) {
+	var tree treeBuilder
+	for _, f := range content.fields() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := tree.add(f); err != nil {
+			return nil, err
+		}
+	}
+	items, err := tree.finish()
+	if err != nil {
+		return nil, err
+	}
+	return toSections(items), nil
+}
+
+func toSections(items Items) []*Section {
+	sections := make([]*Section, len(items))
+	for i, item := range items {
+		sections[i] = item.(*Section)
+	}
+	return sections
+}
+
+// treeBuilder nests a stream of fields into sections at BEGIN and
+// END.
+type treeBuilder struct {
+	stack []*Section
+	items []Items
+	top   Items
+}
+
+func (b *treeBuilder) add(f *icalField) error {
+	switch f.name {
+	case "BEGIN":
+		if f.value == "" {
+			return errors.New("BEGIN without component name")
+		}
+		b.stack = append(b.stack, section(strings.ToUpper(f.value), nil))
+		b.items = append(b.items, Items{})
+	case "END":
+		n := len(b.stack)
+		if n == 0 {
+			return fmt.Errorf("END:%s without BEGIN", f.value)
+		}
+		s := b.stack[n-1]
+		if !strings.EqualFold(s.name, f.value) {
+			return fmt.Errorf("END:%s does not match BEGIN:%s", f.value, s.name)
+		}
+		s.content = b.items[n-1]
+		b.stack, b.items = b.stack[:n-1], b.items[:n-1]
+		if n == 1 {
+			b.top = append(b.top, s)
+		} else {
+			b.items[n-2] = append(b.items[n-2], s)
+		}
+	default:
+		n := len(b.stack)
+		if n == 0 {
+			return fmt.Errorf("property %s outside of a component", f.name)
+		}
+		b.items[n-1] = append(b.items[n-1], f)
+	}
+	return nil
+}
+
+func (b *treeBuilder) finish() (Items, error) {
+	if n := len(b.stack); n > 0 {
+		return nil, fmt.Errorf("missing END:%s", b.stack[n-1].name)
+	}
+	return b.top, nil
+}
+
+func isNameChar(r rune) bool {
+	return r == '-' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9'
+}
+
+// parseName returns the name at the start of s and the rest of s.
+func parseName(s string) (string, string, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !isNameChar(r) })
+	if i < 0 {
+		i = len(s)
+	}
+	if i == 0 {
+		return "", "", fmt.Errorf("expected name: %q", s)
+	}
+	return strings.ToUpper(s[:i]), s[i:], nil
+}
+
+// parseParamValues returns the comma separated, possibly quoted,
+// values at the start of s, and the rest of s.
+func parseParamValues(s string) ([]string, string, error) {
+	var values []string
+	for {
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, "", errors.New("unterminated quoted parameter value")
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexAny(s, `";:,`)
+			if end < 0 {
+				return nil, "", errors.New("missing ':' before value")
+			}
+			if s[end] == '"' {
+				return nil, "", errors.New("unexpected '\"' in parameter value")
+			}
+			value, s = s[:end], s[end:]
+		}
+		values = append(values, value)
+		if !strings.HasPrefix(s, ",") {
+			return values, s, nil
+		}
+		s = s[1:]
+	}
+}
+
+func parseContentLine(line string) (*icalField, error) {
+	f, err := parseRawContentLine(line)
+	if err != nil {
+		return nil, err
+	}
+	if f.ValueType() == TypeText {
+		f.value = unescapeText(f.value)
+	}
+	return f, nil
+}
+
+// parseRawContentLine parses a content line, leaving the value as
+// written.
+func parseRawContentLine(line string) (*icalField, error) {
+	name, rest, err := parseName(line)
+	if err != nil {
+		return nil, err
+	}
+	var attributes []*Attribute
+	for strings.HasPrefix(rest, ";") {
+		var paramName string
+		if paramName, rest, err = parseName(rest[1:]); err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(rest, "=") {
+			return nil, fmt.Errorf("missing '=' after parameter %s", paramName)
+		}
+		var values []string
+		if values, rest, err = parseParamValues(rest[1:]); err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", paramName, err)
+		}
+		for i, value := range values {
+			values[i] = decodeParamValue(value)
+		}
+		attributes = append(attributes, &Attribute{Name: paramName, Value: strings.Join(values, ",")})
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return nil, fmt.Errorf("missing ':' after %s", name)
+	}
+	return field(name, rest[1:], attributes...), nil
+}
+
+// unescapeText reverses the escaping of TEXT values. Unknown escapes
+// are kept as they are.
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			sb.WriteByte('\n')
+		case '\\', ';', ',':
+			sb.WriteByte(s[i])
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// Name returns the component name, like VEVENT.
+func (section *Section) Name() string {
+	return section.name
+}
+
+func (section *Section) items() Items {
+	if items, ok := section.content.(Items); ok {
+		return items
+	}
+	var tree treeBuilder
+	tree.stack = []*Section{section}
+	tree.items = []Items{{}}
+	for _, f := range section.content.fields() {
+		if tree.add(f) != nil || len(tree.stack) == 0 {
+			break
+		}
+	}
+	return tree.items[0]
+}
+
+// Properties returns the properties of the component, without those
+// of its subcomponents.
+func (section *Section) Properties() []*icalField {
+	var buf []*icalField
+	for _, item := range section.items() {
+		if f, ok := item.(*icalField); ok {
+			buf = append(buf, f)
+		}
+	}
+	return buf
+}
+
+// Property returns the first property called name, or nil.
+func (section *Section) Property(name string) *icalField {
+	for _, f := range section.Properties() {
+		if strings.EqualFold(f.name, name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// Components returns the subcomponents, only those called name when
+// given.
+func (section *Section) Components(name ...string) []*Section {
+	var buf []*Section
+	for _, item := range section.items() {
+		if s, ok := item.(*Section); ok && (len(name) == 0 || strings.EqualFold(s.name, name[0])) {
+			buf = append(buf, s)
+		}
+	}
+	return buf
+}
+
+// Name returns the property name, like SUMMARY.
+func (f *icalField) Name() string {
+	return f.name
+}
+
+// Value returns the unescaped property value.
+func (f *icalField) Value() string {
+	return f.value
+}
+
+// Attributes returns the property parameters.
+func (f *icalField) Attributes() []*Attribute {
+	return f.attributes
+}
+
+// Attribute returns the parameter called name, or nil.
+func (f *icalField) Attribute(name string) *Attribute {
+	for _, a := range f.attributes {
+		if strings.EqualFold(a.Name, name) {
+			return a
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseOne(t *testing.T, s string) *Section {
+	sections, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 1 {
+		t.Fatalf("Expected one component, got %d", len(sections))
+	}
+	return sections[0]
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	cal := vcalFixture()
+	cal.events[0].SetDescription(fmt.Sprintf("Line one, with; escapes\\\nLine two %0*d", maxLineLen, 0))
+	expected := Calendar(cal).String()
+	got := parseOne(t, expected).String()
+	if got != expected {
+		t.Fatalf("\n%q\n!=\n%q", got, expected)
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	cal := parseOne(t, Calendar(vcalFixture()).String())
+	if cal.Name() != "VCALENDAR" {
+		t.Fatalf("Unexpected name %s", cal.Name())
+	}
+	if got := cal.Property("prodid").Value(); got != prodID() {
+		t.Fatalf("%s != %s", got, prodID())
+	}
+	events := cal.Components("VEVENT")
+	if len(events) != 1 || len(cal.Components()) != 1 {
+		t.Fatalf("Expected one event, got %d", len(events))
+	}
+	dtStart := events[0].Property("DTSTART")
+	if dtStart.Value() != "20200102" || dtStart.Attribute("VALUE").Value != "DATE" {
+		t.Fatalf("Unexpected DTSTART %+v", dtStart)
+	}
+	if cal.Property("UID") != nil {
+		t.Fatal("Event property found in calendar")
+	}
+}
+
+func TestSectionAccessorsOnGenerated(t *testing.T) {
+	cal := Calendar(vcalFixture())
+	parsed := parseOne(t, cal.String())
+	if len(cal.Properties()) != len(parsed.Properties()) {
+		t.Fatalf("Expected %d properties, got %d", len(parsed.Properties()), len(cal.Properties()))
+	}
+	if !reflect.DeepEqual(cal.Components()[0].Properties(), parsed.Components()[0].Properties()) {
+		t.Fatal("Event properties differ")
+	}
+}
+
+func TestParseUnfoldAndUnescape(t *testing.T) {
+	cal := parseOne(t, "BEGIN:VCALENDAR\nSUMMARY:a\\, b\\; c\\\\d\\Ne\r\n\t f\nEND:VCALENDAR\n")
+	if got := cal.Property("SUMMARY").Value(); got != "a, b; c\\d\ne f" {
+		t.Fatalf("Unexpected value %q", got)
+	}
+}
+
+func TestParseParameters(t *testing.T) {
+	cal := parseOne(t, strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		`ATTENDEE;cn="Doe; John: Jr.";MEMBER="mailto:a@example.com","mailto:b@example.com";ROLE=CHAIR:mailto:john@example.com`,
+		"END:VCALENDAR",
+	}, "\r\n"))
+	attendee := cal.Property("ATTENDEE")
+	expected := []*Attribute{
+		{Name: "CN", Value: "Doe; John: Jr."},
+		{Name: "MEMBER", Value: "mailto:a@example.com,mailto:b@example.com"},
+		{Name: "ROLE", Value: "CHAIR"},
+	}
+	if !reflect.DeepEqual(attendee.Attributes(), expected) {
+		t.Fatalf("%+v != %+v", attendee.Attributes(), expected)
+	}
+	if attendee.Value() != "mailto:john@example.com" {
+		t.Fatalf("Unexpected value %q", attendee.Value())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for input, line := range map[string]int{
+		"SUMMARY:x\r\n":                                        1,
+		"BEGIN:VCALENDAR\r\nEND:VEVENT\r\n":                    2,
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n": 3,
+		"BEGIN:VCALENDAR\r\nSUMMARY\r\nEND:VCALENDAR\r\n":      2,
+		"BEGIN:VCALENDAR\r\nX;A=\"b:c\r\nEND:VCALENDAR\r\n":    2,
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n":                   2,
+		"END:VCALENDAR\r\n":                                    1,
+	} {
+		_, err := Parse(strings.NewReader(input))
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%q: expected ParseError, got %v", input, err)
+		}
+		if parseErr.Line != line {
+			t.Fatalf("%q: expected line %d, got %d: %s", input, line, parseErr.Line, err)
+		}
+	}
+}
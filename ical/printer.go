@@ -1,6 +1,7 @@
 package ical
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -83,9 +84,7 @@ func (p *ContentPrinter) printAttribute(a *Attribute) *ContentPrinter {
 	if p.err != nil {
 		return p
 	}
-	return p.print(a.Name, false).
-		print("=", false).
-		print(a.Value, true)
+	return p.print(a.encode(), false)
 }
 
 func (p *ContentPrinter) printField(f *icalField) *ContentPrinter {
@@ -98,15 +97,24 @@ func (p *ContentPrinter) printField(f *icalField) *ContentPrinter {
 			printAttribute(a)
 	}
 	return p.print(":", false).
-		print(f.value, true).
+		print(f.value, f.ValueType() == TypeText).
 		printLn()
 }
 
 func (p *ContentPrinter) Print(content icalContent) *ContentPrinter {
+	return p.PrintContext(context.Background(), content)
+}
+
+// PrintContext prints content, stopping with the error of ctx when it
+// is done.
+func (p *ContentPrinter) PrintContext(ctx context.Context, content icalContent) *ContentPrinter {
 	if p.err != nil {
 		return p
 	}
 	for _, field := range content.fields() {
+		if p.err = ctx.Err(); p.err != nil {
+			return p
+		}
 		p.printField(field)
 		if p.err != nil {
 			return p
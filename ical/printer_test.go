@@ -1,6 +1,7 @@
 package ical
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -178,3 +179,16 @@ func TestContentString(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestContentPrintContextCanceled(t *testing.T) {
+	var sb = &strings.Builder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := NewContentPrinter(sb).PrintContext(ctx, sectionFixture())
+	if !errors.Is(p.Error(), context.Canceled) {
+		t.Fatalf("Unexpected error %v", p.Error())
+	}
+	if sb.String() != "" {
+		t.Fatalf("Unexpected output %q", sb.String())
+	}
+}
@@ -0,0 +1,167 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	localDateTimeLayout = "20060102T150405"
+	utcDateTimeLayout   = "20060102T150405Z"
+)
+
+// transition is a change of UTC offset, or of daylight saving time,
+// in a location.
+type transition struct {
+	at         time.Time
+	offsetFrom int
+	offsetTo   int
+	name       string
+	dst        bool
+}
+
+type zoneState struct {
+	name   string
+	offset int
+	dst    bool
+}
+
+func stateAt(t time.Time, loc *time.Location) zoneState {
+	local := t.In(loc)
+	name, offset := local.Zone()
+	return zoneState{name: name, offset: offset, dst: local.IsDST()}
+}
+
+// transitions returns the transitions in loc during year, found by
+// probing every day and bisecting down to the second.
+func transitions(loc *time.Location, year int) []*transition {
+	var buf []*transition
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := from.AddDate(1, 0, 0)
+	state := stateAt(from, loc)
+	for from.Before(end) {
+		to := from.Add(day)
+		next := stateAt(to, loc)
+		if next == state {
+			from = to
+			continue
+		}
+		lo, hi := from, to
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2).Truncate(time.Second)
+			if stateAt(mid, loc) == state {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		after := stateAt(hi, loc)
+		buf = append(buf, &transition{
+			at:         hi,
+			offsetFrom: state.offset,
+			offsetTo:   after.offset,
+			name:       after.name,
+			dst:        after.dst,
+		})
+		state, from = after, hi
+	}
+	return buf
+}
+
+// local returns the wall clock time just before the transition, as
+// required for DTSTART in a VTIMEZONE observance.
+func (t *transition) local() time.Time {
+	return t.at.Add(time.Duration(t.offsetFrom) * time.Second).UTC()
+}
+
+// byDay returns the BYDAY rule part matching the day of the
+// transition, like 2SU or -1SU for the last Sunday of the month.
+func byDay(t time.Time) string {
+	weekday := [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}[t.Weekday()]
+	if t.AddDate(0, 0, 7).Month() != t.Month() {
+		return "-1" + weekday
+	}
+	return fmt.Sprintf("%d%s", (t.Day()-1)/7+1, weekday)
+}
+
+// rule returns the yearly recurrence rule of the transition.
+func (t *transition) rule() string {
+	local := t.local()
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%s", int(local.Month()), byDay(local))
+}
+
+// sameRule reports whether a and b recur by the same yearly rule.
+func sameRule(a, b *transition) bool {
+	return a.rule() == b.rule() &&
+		a.local().Format("150405") == b.local().Format("150405") &&
+		a.offsetFrom == b.offsetFrom && a.offsetTo == b.offsetTo &&
+		a.name == b.name && a.dst == b.dst
+}
+
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	s := fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf("%02d", seconds%60)
+	}
+	return s
+}
+
+func observance(t *transition, rule string) *Section {
+	name := "STANDARD"
+	if t.dst {
+		name = "DAYLIGHT"
+	}
+	fields := []*icalField{
+		field("DTSTART", t.local().Format(localDateTimeLayout)),
+		field("TZOFFSETFROM", formatOffset(t.offsetFrom)),
+		field("TZOFFSETTO", formatOffset(t.offsetTo)),
+	}
+	if rule != "" {
+		fields = append(fields, field("RRULE", rule))
+	}
+	fields = append(fields, field("TZNAME", t.name))
+	return section(name, &Fields{Fields: fields})
+}
+
+// VTimezone returns a VTIMEZONE describing loc from the start of
+// firstYear until the end of lastYear. Transitions following the same
+// yearly rule every year are given as one observance with an RRULE,
+// others are listed one by one.
+func VTimezone(loc *time.Location, firstYear, lastYear int) *Section {
+	var all []*transition
+	for year := firstYear; year <= lastYear; year++ {
+		all = append(all, transitions(loc, year)...)
+	}
+	fields := []*icalField{field("TZID", loc.String())}
+	if len(all) == 0 {
+		state := stateAt(time.Date(firstYear, time.January, 1, 0, 0, 0, 0, time.UTC), loc)
+		fixed := &transition{
+			at:         time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC).Add(-time.Duration(state.offset) * time.Second),
+			offsetFrom: state.offset,
+			offsetTo:   state.offset,
+			name:       state.name,
+			dst:        state.dst,
+		}
+		fields = append(fields, observance(fixed, "").fields()...)
+		return section("VTIMEZONE", &Fields{Fields: fields})
+	}
+	perYear := len(transitions(loc, firstYear))
+	regular := perYear > 0 && len(all) == perYear*(lastYear-firstYear+1)
+	for i := perYear; regular && i < len(all); i++ {
+		regular = sameRule(all[i], all[i-perYear])
+	}
+	if regular {
+		for _, t := range all[:perYear] {
+			fields = append(fields, observance(t, t.rule()).fields()...)
+		}
+	} else {
+		for _, t := range all {
+			fields = append(fields, observance(t, "").fields()...)
+		}
+	}
+	return section("VTIMEZONE", &Fields{Fields: fields})
+}
@@ -0,0 +1,99 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skip(err)
+	}
+	return loc
+}
+
+func TestVTimezoneWithRules(t *testing.T) {
+	got := VTimezone(loadLocation(t, "Europe/Oslo"), 2021, 2023).String()
+	expected := strings.Join([]string{
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Oslo",
+		"BEGIN:DAYLIGHT",
+		"DTSTART:20210328T020000",
+		"TZOFFSETFROM:+0100",
+		"TZOFFSETTO:+0200",
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU",
+		"TZNAME:CEST",
+		"END:DAYLIGHT",
+		"BEGIN:STANDARD",
+		"DTSTART:20211031T030000",
+		"TZOFFSETFROM:+0200",
+		"TZOFFSETTO:+0100",
+		"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU",
+		"TZNAME:CET",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"",
+	}, "\r\n")
+	if got != expected {
+		t.Fatalf("\n%s\n!=\n%s", got, expected)
+	}
+}
+
+func TestVTimezoneWithoutTransitions(t *testing.T) {
+	got := VTimezone(loadLocation(t, "Asia/Tokyo"), 2021, 2021).String()
+	for _, expected := range []string{"DTSTART:19700101T000000\r\n", "TZOFFSETFROM:+0900\r\n", "TZOFFSETTO:+0900\r\n"} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("Expected %q in\n%s", expected, got)
+		}
+	}
+}
+
+func TestVTimezoneIrregular(t *testing.T) {
+	// Moscow stopped changing to winter time in 2011.
+	got := VTimezone(loadLocation(t, "Europe/Moscow"), 2010, 2011).String()
+	if strings.Contains(got, "RRULE") {
+		t.Fatalf("Unexpected RRULE in\n%s", got)
+	}
+	if n := strings.Count(got, "BEGIN:DAYLIGHT") + strings.Count(got, "BEGIN:STANDARD"); n != 3 {
+		t.Fatalf("Expected 3 observances, got %d in\n%s", n, got)
+	}
+}
+
+func TestByDay(t *testing.T) {
+	for date, expected := range map[string]string{
+		"2021-03-28": "-1SU",
+		"2021-03-14": "2SU",
+		"2021-04-04": "1SU",
+		"2021-11-07": "1SU",
+	} {
+		d, _ := time.Parse(time.DateOnly, date)
+		if got := byDay(d); got != expected {
+			t.Fatalf("%s: %s != %s", date, got, expected)
+		}
+	}
+}
+
+func TestFormatOffset(t *testing.T) {
+	for seconds, expected := range map[int]string{3600: "+0100", -12600: "-0330", 0: "+0000", 3661: "+010101"} {
+		if got := formatOffset(seconds); got != expected {
+			t.Fatalf("%d: %s != %s", seconds, got, expected)
+		}
+	}
+}
+
+func TestTimedEvent(t *testing.T) {
+	cal := vcalFixture()
+	start := time.Date(2021, 12, 28, 10, 0, 0, 0, time.UTC)
+	cal.events[0].SetTime(start, start.Add(4*time.Hour))
+	got := Calendar(cal).String()
+	for _, expected := range []string{"DTSTART:20211228T100000Z\r\n", "DTEND:20211228T140000Z\r\n"} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("Expected %q in\n%s", expected, got)
+		}
+	}
+	if strings.Contains(got, "VTIMEZONE") {
+		t.Fatalf("Unexpected VTIMEZONE for UTC in\n%s", got)
+	}
+}
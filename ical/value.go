@@ -0,0 +1,106 @@
+package ical
+
+import "strings"
+
+// ValueType is the type of a property value, deciding how it is
+// encoded.
+type ValueType string
+
+const (
+	TypeBinary     ValueType = "BINARY"
+	TypeBoolean    ValueType = "BOOLEAN"
+	TypeCalAddress ValueType = "CAL-ADDRESS"
+	TypeDate       ValueType = "DATE"
+	TypeDateTime   ValueType = "DATE-TIME"
+	TypeDuration   ValueType = "DURATION"
+	TypeFloat      ValueType = "FLOAT"
+	TypeInteger    ValueType = "INTEGER"
+	TypePeriod     ValueType = "PERIOD"
+	TypeRecur      ValueType = "RECUR"
+	TypeText       ValueType = "TEXT"
+	TypeTime       ValueType = "TIME"
+	TypeURI        ValueType = "URI"
+	TypeUTCOffset  ValueType = "UTC-OFFSET"
+)
+
+// propertyTypes are the default value types of the properties in RFC
+// 5545 not taking TEXT.
+var propertyTypes = map[string]ValueType{
+	"ATTACH":           TypeURI,
+	"ATTENDEE":         TypeCalAddress,
+	"COMPLETED":        TypeDateTime,
+	"CREATED":          TypeDateTime,
+	"DTEND":            TypeDateTime,
+	"DTSTAMP":          TypeDateTime,
+	"DTSTART":          TypeDateTime,
+	"DUE":              TypeDateTime,
+	"DURATION":         TypeDuration,
+	"EXDATE":           TypeDateTime,
+	"FREEBUSY":         TypePeriod,
+	"GEO":              TypeFloat,
+	"LAST-MODIFIED":    TypeDateTime,
+	"ORGANIZER":        TypeCalAddress,
+	"PERCENT-COMPLETE": TypeInteger,
+	"PRIORITY":         TypeInteger,
+	"RDATE":            TypeDateTime,
+	"RECURRENCE-ID":    TypeDateTime,
+	"REPEAT":           TypeInteger,
+	"RRULE":            TypeRecur,
+	"SEQUENCE":         TypeInteger,
+	"TRIGGER":          TypeDuration,
+	"TZOFFSETFROM":     TypeUTCOffset,
+	"TZOFFSETTO":       TypeUTCOffset,
+	"TZURL":            TypeURI,
+	"URL":              TypeURI,
+}
+
+// multiValueParams are the parameters taking a comma separated list
+// of values, each quoted on its own.
+var multiValueParams = map[string]bool{
+	"DELEGATED-FROM": true,
+	"DELEGATED-TO":   true,
+	"MEMBER":         true,
+}
+
+// ValueType returns the type given by the VALUE parameter, or the
+// default type of the property. Unknown properties take TEXT.
+func (f *icalField) ValueType() ValueType {
+	if a := f.Attribute("VALUE"); a != nil {
+		return ValueType(strings.ToUpper(a.Value))
+	}
+	if t, ok := propertyTypes[strings.ToUpper(f.name)]; ok {
+		return t
+	}
+	return TypeText
+}
+
+// encodeParamValue encodes a parameter value with the caret escapes
+// of RFC 6868, in DQUOTEs when it contains ':', ';' or ','.
+func encodeParamValue(s string) string {
+	s = strings.NewReplacer("^", "^^", "\n", "^n", `"`, "^'").Replace(s)
+	if strings.ContainsAny(s, ":;,") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// decodeParamValue reverses the caret escapes of RFC 6868. Unknown
+// escapes are kept as they are.
+func decodeParamValue(s string) string {
+	if !strings.Contains(s, "^") {
+		return s
+	}
+	return strings.NewReplacer("^^", "^", "^n", "\n", "^N", "\n", "^'", `"`).Replace(s)
+}
+
+// encode returns the attribute as written in a content line.
+func (a *Attribute) encode() string {
+	values := []string{a.Value}
+	if multiValueParams[strings.ToUpper(a.Name)] {
+		values = strings.Split(a.Value, ",")
+	}
+	for i, value := range values {
+		values[i] = encodeParamValue(value)
+	}
+	return a.Name + "=" + strings.Join(values, ",")
+}
@@ -0,0 +1,80 @@
+package ical
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueType(t *testing.T) {
+	for f, expected := range map[*icalField]ValueType{
+		field("SUMMARY", "x"):                         TypeText,
+		field("X-UNKNOWN", "x"):                       TypeText,
+		field("url", "x"):                             TypeURI,
+		field("DTSTART", "20211228T100000Z"):          TypeDateTime,
+		field("DTSTART", "20211228", dateAttribute()): TypeDate,
+		field("RRULE", "FREQ=YEARLY"):                 TypeRecur,
+		field("X-DATE", "20211228", &Attribute{Name: "value", Value: "date"}): TypeDate,
+	} {
+		if got := f.ValueType(); got != expected {
+			t.Fatalf("%s: %s != %s", f.name, got, expected)
+		}
+	}
+}
+
+func TestPrintURLUnescaped(t *testing.T) {
+	u, _ := url.Parse("https://example.com/?code=6666,1234;x=y")
+	event := NewVEvent("UID", u, "A, B; C", timestamp())
+	got := event.URL()
+	var sb strings.Builder
+	NewContentPrinter(&sb).printField(got).printField(event.Summary())
+	expected := "URL:https://example.com/?code=6666,1234;x=y\r\nSUMMARY:A\\, B\\; C\r\n"
+	if sb.String() != expected {
+		t.Fatalf("%q != %q", sb.String(), expected)
+	}
+}
+
+func TestPrintParameters(t *testing.T) {
+	f := field("ATTENDEE", "mailto:a@example.com",
+		&Attribute{Name: "CN", Value: `Doe; "John"`},
+		&Attribute{Name: "MEMBER", Value: "mailto:b@example.com,mailto:c@example.com"},
+		&Attribute{Name: "X-NOTE", Value: "^a\nb"},
+		&Attribute{Name: "ROLE", Value: "CHAIR"},
+	)
+	var sb strings.Builder
+	NewContentPrinter(&sb).printField(f)
+	expected := `ATTENDEE;CN="Doe; ^'John^'";MEMBER="mailto:b@example.com","mailto:c@example.com";` +
+		"X-NOTE=^^a^nb;ROLE=CHAIR:mailto:a@example.com\r\n"
+	got := strings.ReplaceAll(sb.String(), "\r\n ", "")
+	if got != expected {
+		t.Fatalf("\n%q\n!=\n%q", got, expected)
+	}
+	parsed := parseOne(t, "BEGIN:X\r\n"+sb.String()+"END:X\r\n").Property("ATTENDEE")
+	for i, a := range parsed.Attributes() {
+		if a.Value != f.attributes[i].Value {
+			t.Fatalf("%s: %q != %q", a.Name, a.Value, f.attributes[i].Value)
+		}
+	}
+}
+
+func TestParseRoundTripTimezone(t *testing.T) {
+	cal := vcalFixture()
+	loc := loadLocation(t, "Europe/Oslo")
+	start := time.Date(2021, 12, 28, 10, 0, 0, 0, loc)
+	cal.events[0].SetTime(start, start.Add(4*time.Hour))
+	expected := Calendar(cal).String()
+	if got := parseOne(t, expected).String(); got != expected {
+		t.Fatalf("\n%s\n!=\n%s", got, expected)
+	}
+}
+
+func TestParseLeavesNonTextValues(t *testing.T) {
+	cal := parseOne(t, "BEGIN:X\r\nURL:https://example.com/a\\,b\r\nSUMMARY:a\\,b\r\nEND:X\r\n")
+	if got := cal.Property("URL").Value(); got != `https://example.com/a\,b` {
+		t.Fatalf("Unexpected URL %q", got)
+	}
+	if got := cal.Property("SUMMARY").Value(); got != "a,b" {
+		t.Fatalf("Unexpected SUMMARY %q", got)
+	}
+}
@@ -0,0 +1,209 @@
+package ical
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+const xcalNamespace = "urn:ietf:params:xml:ns:icalendar-2.0"
+
+// paramTypes are the value types of parameters not taking TEXT.
+var paramTypes = map[string]ValueType{
+	"ALTREP":         TypeURI,
+	"DELEGATED-FROM": TypeCalAddress,
+	"DELEGATED-TO":   TypeCalAddress,
+	"DIR":            TypeURI,
+	"MEMBER":         TypeCalAddress,
+	"SENT-BY":        TypeCalAddress,
+}
+
+// XCalPrinter writes content as RFC 6321 xCal, the XML form of
+// iCalendar. It walks the same field stream as ContentPrinter.
+type XCalPrinter struct {
+	writer io.Writer
+	err    error
+}
+
+func NewXCalPrinter(wr io.Writer) *XCalPrinter {
+	return &XCalPrinter{writer: wr}
+}
+
+func (p *XCalPrinter) Error() error {
+	return p.err
+}
+
+func (p *XCalPrinter) Print(content icalContent) *XCalPrinter {
+	return p.PrintContext(context.Background(), content)
+}
+
+// xcalEncoder writes elements, keeping the first error.
+type xcalEncoder struct {
+	*xml.Encoder
+	err error
+}
+
+func (e *xcalEncoder) token(t xml.Token) {
+	if e.err == nil {
+		e.err = e.EncodeToken(t)
+	}
+}
+
+func (e *xcalEncoder) start(name string) {
+	e.token(xml.StartElement{Name: xml.Name{Local: name}})
+}
+
+func (e *xcalEncoder) end(name string) {
+	e.token(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func (e *xcalEncoder) element(name, text string) {
+	e.start(name)
+	e.token(xml.CharData(text))
+	e.end(name)
+}
+
+// PrintContext prints content in an icalendar element, stopping with
+// the error of ctx when it is done.
+func (p *XCalPrinter) PrintContext(ctx context.Context, content icalContent) *XCalPrinter {
+	if p.err != nil {
+		return p
+	}
+	sections, err := nest(ctx, content)
+	if p.err = err; err != nil {
+		return p
+	}
+	if _, p.err = io.WriteString(p.writer, xml.Header); p.err != nil {
+		return p
+	}
+	e := &xcalEncoder{Encoder: xml.NewEncoder(p.writer)}
+	e.Indent("", "  ")
+	e.token(xml.StartElement{
+		Name: xml.Name{Local: "icalendar"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: xcalNamespace}},
+	})
+	for _, s := range sections {
+		e.component(s)
+	}
+	e.end("icalendar")
+	if e.err == nil {
+		e.err = e.Flush()
+	}
+	if p.err = e.err; p.err == nil {
+		_, p.err = io.WriteString(p.writer, "\n")
+	}
+	return p
+}
+
+func (e *xcalEncoder) component(s *Section) {
+	name := strings.ToLower(s.name)
+	e.start(name)
+	if properties := s.Properties(); len(properties) > 0 {
+		e.start("properties")
+		for _, f := range properties {
+			e.property(f)
+		}
+		e.end("properties")
+	}
+	if components := s.Components(); len(components) > 0 {
+		e.start("components")
+		for _, sub := range components {
+			e.component(sub)
+		}
+		e.end("components")
+	}
+	e.end(name)
+}
+
+func (e *xcalEncoder) property(f *icalField) {
+	name := strings.ToLower(f.name)
+	e.start(name)
+	var params []*Attribute
+	for _, a := range f.attributes {
+		if !strings.EqualFold(a.Name, "VALUE") {
+			params = append(params, a)
+		}
+	}
+	if len(params) > 0 {
+		e.start("parameters")
+		for _, a := range params {
+			e.parameter(a)
+		}
+		e.end("parameters")
+	}
+	e.value(f.ValueType(), f.value)
+	e.end(name)
+}
+
+func (e *xcalEncoder) parameter(a *Attribute) {
+	name := strings.ToUpper(a.Name)
+	valueType, ok := paramTypes[name]
+	if !ok {
+		valueType = TypeText
+	}
+	values := []string{a.Value}
+	if multiValueParams[name] {
+		values = strings.Split(a.Value, ",")
+	}
+	e.start(strings.ToLower(name))
+	for _, v := range values {
+		e.element(strings.ToLower(string(valueType)), v)
+	}
+	e.end(strings.ToLower(name))
+}
+
+func (e *xcalEncoder) value(valueType ValueType, value string) {
+	typeName := strings.ToLower(string(valueType))
+	if !knownTypes[valueType] {
+		typeName = "unknown"
+	}
+	switch valueType {
+	case TypeRecur:
+		e.recur(value)
+	case TypePeriod:
+		start, end, _ := strings.Cut(value, "/")
+		e.start(typeName)
+		e.element("start", isoDateTime(start))
+		if strings.Contains(end, "P") {
+			e.element("duration", end)
+		} else {
+			e.element("end", isoDateTime(end))
+		}
+		e.end(typeName)
+	default:
+		e.element(typeName, xcalText(valueType, value))
+	}
+}
+
+// xcalText converts the values written as text in xCal, with dates,
+// times and offsets in their ISO 8601 extended form.
+func xcalText(valueType ValueType, value string) string {
+	switch valueType {
+	case TypeDate:
+		return isoDate(value)
+	case TypeDateTime:
+		return isoDateTime(value)
+	case TypeTime:
+		return isoTime(value)
+	case TypeUTCOffset:
+		return isoUTCOffset(value)
+	}
+	return value
+}
+
+// recur writes a RECUR value as an element per rule part value.
+func (e *xcalEncoder) recur(value string) {
+	e.start("recur")
+	for _, part := range strings.Split(value, ";") {
+		key, values, _ := strings.Cut(part, "=")
+		key = strings.ToLower(key)
+		for _, v := range strings.Split(values, ",") {
+			if key == "until" {
+				v = isoDateTime(v)
+			}
+			e.element(key, v)
+		}
+	}
+	e.end("recur")
+}
@@ -0,0 +1,98 @@
+package ical
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// printXCal prints content as xCal, checking it is well formed XML and
+// returning it without indentation.
+func printXCal(t *testing.T, content icalContent) string {
+	var sb strings.Builder
+	if err := NewXCalPrinter(&sb).Print(content).Error(); err != nil {
+		t.Fatal(err)
+	}
+	decoder := xml.NewDecoder(strings.NewReader(sb.String()))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("%s: %s", err, sb.String())
+			}
+			break
+		}
+	}
+	lines := strings.Split(sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "")
+}
+
+func expectXCal(t *testing.T, got string, expected ...string) {
+	for _, s := range expected {
+		if !strings.Contains(got, s) {
+			t.Fatalf("%s not in\n%s", s, got)
+		}
+	}
+}
+
+func TestXCalCalendar(t *testing.T) {
+	cal := vcalFixture()
+	cal.events[0].AddAlarm(NewVAlarm(-time.Hour, "Alarm"))
+	got := printXCal(t, Calendar(cal))
+	expectXCal(t, got,
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<icalendar xmlns="urn:ietf:params:xml:ns:icalendar-2.0"><vcalendar><properties>`,
+		"<prodid><text>"+prodID()+"</text></prodid>",
+		"<url><uri>https://www.example.com</uri></url>",
+		"<dtstart><date>2020-01-02</date></dtstart>",
+		"<dtstamp><date-time>"+timestamp().UTC().Format("2006-01-02T15:04:05Z")+"</date-time></dtstamp>",
+		"<valarm><properties><action><text>DISPLAY</text></action><trigger><duration>-PT1H</duration></trigger>",
+		"</vevent></components></vcalendar></icalendar>",
+	)
+}
+
+func TestXCalTimezone(t *testing.T) {
+	got := printXCal(t, VTimezone(loadLocation(t, "Europe/Oslo"), 2021, 2021))
+	expectXCal(t, got,
+		"<dtstart><date-time>2021-03-28T02:00:00</date-time></dtstart>",
+		"<tzoffsetto><utc-offset>+02:00</utc-offset></tzoffsetto>",
+		"<rrule><recur><freq>YEARLY</freq><bymonth>3</bymonth><byday>-1SU</byday></recur></rrule>",
+	)
+}
+
+func TestXCalValues(t *testing.T) {
+	for _, tc := range []struct {
+		f        *icalField
+		expected string
+	}{
+		{field("DTSTART", "20211228T100000", &Attribute{Name: "TZID", Value: "Europe/Oslo"}),
+			"<dtstart><parameters><tzid><text>Europe/Oslo</text></tzid></parameters><date-time>2021-12-28T10:00:00</date-time></dtstart>"},
+		{field("ATTENDEE", "mailto:a@example.com", &Attribute{Name: "MEMBER", Value: "mailto:b@example.com,mailto:c@example.com"}),
+			"<attendee><parameters><member><cal-address>mailto:b@example.com</cal-address><cal-address>mailto:c@example.com</cal-address>" +
+				"</member></parameters><cal-address>mailto:a@example.com</cal-address></attendee>"},
+		{field("RRULE", "FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20220101T000000Z"),
+			"<rrule><recur><freq>WEEKLY</freq><byday>MO</byday><byday>WE</byday><until>2022-01-01T00:00:00Z</until></recur></rrule>"},
+		{field("FREEBUSY", "20220101T100000Z/PT1H"),
+			"<freebusy><period><start>2022-01-01T10:00:00Z</start><duration>PT1H</duration></period></freebusy>"},
+		{field("SUMMARY", "a & <b>"), "<summary><text>a &amp; &lt;b&gt;</text></summary>"},
+		{field("X-THING", "x", &Attribute{Name: "VALUE", Value: "X-NAME"}), "<x-thing><unknown>x</unknown></x-thing>"},
+	} {
+		expectXCal(t, printXCal(t, section("VCALENDAR", &Fields{Fields: []*icalField{tc.f}})), tc.expected)
+	}
+}
+
+func TestXCalPrintContextCanceled(t *testing.T) {
+	var sb strings.Builder
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := NewXCalPrinter(&sb).PrintContext(ctx, sectionFixture())
+	if !errors.Is(p.Error(), context.Canceled) || sb.String() != "" {
+		t.Fatalf("Unexpected error %v, output %q", p.Error(), sb.String())
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultLanguage = "nb"
+
+// languageT holds the words used in the calendar. The summary is a
+// fmt format taking the postal code (with place name when known), the
+// weekday name and the day of the month. The words for changes are
+// used in notifications, changed is a fmt format taking the postal
+// code.
+type languageT struct {
+	weekdayNames     map[time.Weekday]string
+	monthNames       map[time.Month]string
+	summary          string
+	placeDescription string
+	municipality     string
+	alarm            string
+	changed          string
+	added            string
+	removed          string
+	moved            string
+}
+
+// norwegianMonthNames are the same in bokmål and nynorsk.
+var norwegianMonthNames = map[time.Month]string{
+	time.January:   "januar",
+	time.February:  "februar",
+	time.March:     "mars",
+	time.April:     "april",
+	time.May:       "mai",
+	time.June:      "juni",
+	time.July:      "juli",
+	time.August:    "august",
+	time.September: "september",
+	time.October:   "oktober",
+	time.November:  "november",
+	time.December:  "desember",
+}
+
+var languages = map[string]*languageT{
+	"nb": {
+		weekdayNames:     weekdayNames,
+		monthNames:       norwegianMonthNames,
+		summary:          "%s: Posten kommer %s %d.",
+		placeDescription: "Postlevering i",
+		municipality:     "kommune",
+		alarm:            "Tøm postkassen",
+		changed:          "Leveringsdagene for %s er endret:",
+		added:            "ny",
+		removed:          "fjernet",
+		moved:            "flyttet",
+	},
+	"nn": {
+		weekdayNames: map[time.Weekday]string{
+			time.Monday:    "måndag",
+			time.Tuesday:   "tysdag",
+			time.Wednesday: "onsdag",
+			time.Thursday:  "torsdag",
+			time.Friday:    "fredag",
+			time.Saturday:  "laurdag",
+			time.Sunday:    "sundag",
+		},
+		monthNames:       norwegianMonthNames,
+		summary:          "%s: Posten kjem %s %d.",
+		placeDescription: "Postlevering i",
+		municipality:     "kommune",
+		alarm:            "Tøm postkassen",
+		changed:          "Leveringsdagane for %s er endra:",
+		added:            "ny",
+		removed:          "fjerna",
+		moved:            "flytta",
+	},
+	"en": {
+		weekdayNames: map[time.Weekday]string{
+			time.Monday:    "Monday",
+			time.Tuesday:   "Tuesday",
+			time.Wednesday: "Wednesday",
+			time.Thursday:  "Thursday",
+			time.Friday:    "Friday",
+			time.Saturday:  "Saturday",
+			time.Sunday:    "Sunday",
+		},
+		monthNames: map[time.Month]string{
+			time.January:   "January",
+			time.February:  "February",
+			time.March:     "March",
+			time.April:     "April",
+			time.May:       "May",
+			time.June:      "June",
+			time.July:      "July",
+			time.August:    "August",
+			time.September: "September",
+			time.October:   "October",
+			time.November:  "November",
+			time.December:  "December",
+		},
+		summary:          "%s: Mail delivery %s %d.",
+		placeDescription: "Mail delivery in",
+		municipality:     "municipality",
+		alarm:            "Empty the mailbox",
+		changed:          "Delivery dates for %s changed:",
+		added:            "added",
+		removed:          "removed",
+		moved:            "moved",
+	},
+}
+
+func languageNames() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func toLanguage(name string) (*languageT, error) {
+	if lang, ok := languages[name]; ok {
+		return lang, nil
+	}
+	return nil, fmt.Errorf("unknown language %q, expected one of: %s", name, strings.Join(languageNames(), ", "))
+}
+
+// checkSummaryFormat verifies that format takes the arguments of a
+// summary.
+func checkSummaryFormat(format string) error {
+	if s := fmt.Sprintf(format, "6666", "tirsdag", 28); strings.Contains(s, "%!") {
+		return fmt.Errorf("invalid summary format %q: %s", format, s)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLanguagesHaveAllNames(t *testing.T) {
+	for name, lang := range languages {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if lang.weekdayNames[d] == "" {
+				t.Fatalf("%s: no name for %s", name, d)
+			}
+		}
+		for m := time.January; m <= time.December; m++ {
+			if lang.monthNames[m] == "" {
+				t.Fatalf("%s: no name for %s", name, m)
+			}
+		}
+		if err := checkSummaryFormat(lang.summary); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if lang.added == "" || lang.removed == "" || lang.moved == "" || strings.Count(lang.changed, "%s") != 1 {
+			t.Fatalf("%s: missing words for changes", name)
+		}
+	}
+}
+
+func TestToLanguage(t *testing.T) {
+	if _, err := toLanguage("sv"); err == nil || !strings.Contains(err.Error(), "en, nb, nn") {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestCheckSummaryFormat(t *testing.T) {
+	for _, format := range []string{"%s %s", "%s %s %s", "%d %s %d"} {
+		if err := checkSummaryFormat(format); err == nil {
+			t.Fatalf("Expected error for %q", format)
+		}
+	}
+	if err := checkSummaryFormat("%[3]d. %[2]s: post til %[1]s"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCalendarLanguage(t *testing.T) {
+	cal := calendarTFixture()
+	cal.language = languages["en"]
+	if got := cal.summaryFormat(); got != languages["en"].summary {
+		t.Fatalf("Unexpected summary %s", got)
+	}
+	got := toVCalendar(cal).String()
+	if !strings.Contains(got, "SUMMARY:6666: Mail delivery Tuesday 28.\r\n") {
+		t.Fatalf("Unexpected calendar\n%s", got)
+	}
+	cal.summary = "%[3]d. %[2]s: post til %[1]s"
+	got = toVCalendar(cal).String()
+	if !strings.Contains(got, "SUMMARY:28. Tuesday: post til 6666\r\n") {
+		t.Fatalf("Unexpected calendar\n%s", got)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/taasan/postgang/ical"
+)
+
+// lintFile writes the problems found in the ICS stream r to w,
+// prefixed by name, and returns how many there were.
+func lintFile(name string, r io.Reader, w io.Writer) (int, error) {
+	problems, err := ical.Lint(r)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	for _, p := range problems {
+		if _, err = fmt.Fprintf(w, "%s:%d: %s\n", name, p.Line, p.Message); err != nil {
+			return 0, err
+		}
+	}
+	return len(problems), nil
+}
+
+// lintFiles checks the named files, standard input for -, and returns
+// the number of problems found.
+func lintFiles(names []string, w io.Writer) (int, error) {
+	total := 0
+	for _, name := range names {
+		var n int
+		var err error
+		if name == "-" {
+			n, err = lintFile("<stdin>", os.Stdin, w)
+		} else {
+			var f *os.File
+			if f, err = os.Open(name); err != nil {
+				return total, err
+			}
+			n, err = lintFile(name, f, w)
+			f.Close()
+		}
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func lintCli(_ context.Context, as []string) {
+	cmd := flag.NewFlagSet("lint", flag.ExitOnError)
+	cmd.Usage = func() {
+		fmt.Fprintf(cmd.Output(), "Usage: %s lint [file ...]\n\n", os.Args[0])
+		fmt.Fprintln(cmd.Output(), "Check ICS files against RFC 5545, standard input when no file or - is given.")
+		cmd.PrintDefaults()
+	}
+	if err := cmd.Parse(as); err != nil {
+		die(err)
+	}
+	names := cmd.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	n, err := lintFiles(names, os.Stdout)
+	if err != nil {
+		die(err)
+	}
+	if n > 0 {
+		os.Exit(1)
+	}
+}
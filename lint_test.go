@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLintFixture(t *testing.T) {
+	var out strings.Builder
+	n, err := lintFiles([]string{"test/fixture.ics"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("Unexpected problems:\n%s", out.String())
+	}
+}
+
+func TestLintGeneratedCalendars(t *testing.T) {
+	first, second := calendarTFixture(), calendarTFixture()
+	second.code, _ = toPostalCode("1234")
+	second.place = &placeT{City: "OSLO", Municipality: "OSLO"}
+	second.window, _ = parseWindow("10:00-14:00")
+	second.alarms = []time.Duration{-5 * time.Hour, 7 * time.Hour}
+	var out strings.Builder
+	n, err := lintFile("generated", strings.NewReader(toVCalendar(first, second).String()), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("Unexpected problems:\n%s", out.String())
+	}
+}
+
+func TestLintReportsFileAndLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.ics")
+	if err := os.WriteFile(path, []byte("BEGIN:VCALENDAR\nVERSION:2.0\r\nEND:VCALENDAR\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	n, err := lintFiles([]string{path}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := path + ":1: bare LF line ending\n" + path + ":1: VCALENDAR: missing required property PRODID\n"
+	if n != 2 || out.String() != expected {
+		t.Fatalf("%d problems:\n%s\n!=\n%s", n, out.String(), expected)
+	}
+	if _, err = lintFiles([]string{filepath.Join(t.TempDir(), "missing.ics")}, &out); err == nil {
+		t.Fatal("Expected error")
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	notifyStdout        = "stdout"
+	notifyCommandPrefix = "command:"
+)
+
+// snapshotT maps postal codes to the delivery dates seen by the last
+// run.
+type snapshotT map[string][]string
+
+func readSnapshot(path string) (snapshotT, error) {
+	snapshot := snapshotT{}
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshot, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(bs, &snapshot); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+func (snapshot snapshotT) write(path string) error {
+	bs, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(bs, '\n'))
+}
+
+type dateMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// scheduleChange is how the future delivery dates of a postal code
+// changed since the last run.
+type scheduleChange struct {
+	Code    string      `json:"code"`
+	Added   []string    `json:"added,omitempty"`
+	Removed []string    `json:"removed,omitempty"`
+	Moved   []*dateMove `json:"moved,omitempty"`
+}
+
+func isoWeek(date string) string {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return date
+	}
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// diffDates compares the dates from today on. A removed date is taken
+// as moved to an added date in the same week.
+func diffDates(code string, old, current []string, today string) *scheduleChange {
+	inCurrent := map[string]bool{}
+	for _, date := range current {
+		inCurrent[date] = true
+	}
+	inOld := map[string]bool{}
+	change := &scheduleChange{Code: code}
+	for _, date := range old {
+		inOld[date] = true
+		if date >= today && !inCurrent[date] {
+			change.Removed = append(change.Removed, date)
+		}
+	}
+	for _, date := range current {
+		if date >= today && !inOld[date] {
+			change.Added = append(change.Added, date)
+		}
+	}
+	sort.Strings(change.Removed)
+	sort.Strings(change.Added)
+	var removed []string
+	for _, from := range change.Removed {
+		moved := false
+		for i, to := range change.Added {
+			if isoWeek(from) == isoWeek(to) {
+				change.Moved = append(change.Moved, &dateMove{From: from, To: to})
+				change.Added = append(change.Added[:i], change.Added[i+1:]...)
+				moved = true
+				break
+			}
+		}
+		if !moved {
+			removed = append(removed, from)
+		}
+	}
+	change.Removed = removed
+	if len(change.Added) == 0 && len(change.Removed) == 0 && len(change.Moved) == 0 {
+		return nil
+	}
+	return change
+}
+
+// detectChanges compares the calendars with the snapshot, updating
+// it. Postal codes not in the snapshot are not reported.
+func (snapshot snapshotT) detectChanges(cals []*calendarT) []*scheduleChange {
+	var changes []*scheduleChange
+	for _, cal := range cals {
+		current := make([]string, len(cal.dates))
+		for i, date := range cal.dates {
+			current[i] = date.time.Format(time.DateOnly)
+		}
+		old, ok := snapshot[cal.code.code]
+		snapshot[cal.code.code] = current
+		if !ok {
+			continue
+		}
+		if change := diffDates(cal.code.code, old, current, cal.now.In(timezone).Format(time.DateOnly)); change != nil {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+func describeDate(date string, lang *languageT) string {
+	if t, err := time.Parse(time.DateOnly, date); err == nil {
+		return fmt.Sprintf("%s %s", lang.weekdayNames[t.Weekday()], date)
+	}
+	return date
+}
+
+// formatChanges returns the changes as text in lang, a line per date.
+func formatChanges(changes []*scheduleChange, lang *languageT) string {
+	width := 0
+	for _, word := range []string{lang.added, lang.removed, lang.moved} {
+		if n := utf8.RuneCountInString(word); n > width {
+			width = n
+		}
+	}
+	var sb strings.Builder
+	for _, change := range changes {
+		fmt.Fprintf(&sb, lang.changed+"\n", change.Code)
+		for _, date := range change.Added {
+			fmt.Fprintf(&sb, "  %-*s %s\n", width, lang.added, describeDate(date, lang))
+		}
+		for _, date := range change.Removed {
+			fmt.Fprintf(&sb, "  %-*s %s\n", width, lang.removed, describeDate(date, lang))
+		}
+		for _, move := range change.Moved {
+			fmt.Fprintf(&sb, "  %-*s %s -> %s\n", width, lang.moved, describeDate(move.From, lang), describeDate(move.To, lang))
+		}
+	}
+	return sb.String()
+}
+
+// notifier sends schedule changes somewhere.
+type notifier interface {
+	notify(ctx context.Context, changes []*scheduleChange, text string) error
+}
+
+type writerNotifier struct {
+	writer io.Writer
+}
+
+func (n *writerNotifier) notify(_ context.Context, _ []*scheduleChange, text string) error {
+	_, err := io.WriteString(n.writer, text)
+	return err
+}
+
+// webhookNotifier POSTs the changes as JSON.
+type webhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+type webhookPayload struct {
+	Text    string            `json:"text"`
+	Changes []*scheduleChange `json:"changes"`
+}
+
+func (n *webhookNotifier) notify(ctx context.Context, changes []*scheduleChange, text string) error {
+	bs, err := json.Marshal(&webhookPayload{Text: text, Changes: changes})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: got HTTP error: %s", n.url, resp.Status)
+	}
+	return nil
+}
+
+// commandNotifier runs a command with the text on standard input.
+type commandNotifier struct {
+	args []string
+}
+
+func (n *commandNotifier) notify(ctx context.Context, _ []*scheduleChange, text string) error {
+	cmd := exec.CommandContext(ctx, n.args[0], n.args[1:]...) //nolint:gosec
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", strings.Join(n.args, " "), err)
+	}
+	return nil
+}
+
+// newNotifier returns the notifier for spec: stdout, an http or https
+// URL for a webhook, or command: followed by a command and its
+// arguments separated by spaces.
+func newNotifier(spec string, client *http.Client) (notifier, error) {
+	switch {
+	case spec == notifyStdout:
+		return &writerNotifier{writer: os.Stdout}, nil
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return &webhookNotifier{client: client, url: spec}, nil
+	case strings.HasPrefix(spec, notifyCommandPrefix):
+		args := strings.Fields(strings.TrimPrefix(spec, notifyCommandPrefix))
+		if len(args) == 0 {
+			return nil, fmt.Errorf("no command given in %q", spec)
+		}
+		return &commandNotifier{args: args}, nil
+	}
+	return nil, fmt.Errorf("unknown notification %q, expected stdout, a webhook URL or %sCOMMAND", spec, notifyCommandPrefix)
+}
+
+// notifyChanges sends the changes since the snapshot of the job to all
+// its notifiers. The snapshot is only saved when all succeed, so
+// failed notifications are tried again by the next run.
+func (args *commandLineArgs) notifyChanges(ctx context.Context, job *jobT, cals []*calendarT) error {
+	snapshot, err := readSnapshot(job.snapshotPath)
+	if err != nil {
+		return err
+	}
+	if changes := snapshot.detectChanges(cals); len(changes) > 0 {
+		// The calendars of a job share its language.
+		text := formatChanges(changes, cals[0].lang())
+		var errs []error
+		for _, spec := range job.notify {
+			n, err := newNotifier(spec, args.notifyClient)
+			if err == nil {
+				err = n.notify(ctx, changes, text)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err = errors.Join(errs...); err != nil {
+			return err
+		}
+	}
+	return snapshot.write(job.snapshotPath)
+}
+
+// notifyFlag is a flag.Value collecting notification specs from
+// repeated flags.
+type notifyFlag struct {
+	specs *[]string
+}
+
+func (f notifyFlag) String() string {
+	if f.specs == nil {
+		return ""
+	}
+	return strings.Join(*f.specs, ",")
+}
+
+func (f notifyFlag) Set(value string) error {
+	if _, err := newNotifier(value, nil); err != nil {
+		return err
+	}
+	*f.specs = append(*f.specs, value)
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffDates(t *testing.T) {
+	old := []string{"2021-12-27", "2021-12-28", "2021-12-29", "2021-12-30"}
+	current := []string{"2021-12-28", "2021-12-31", "2022-01-04"}
+	got := diffDates("6666", old, current, "2021-12-28")
+	expected := &scheduleChange{
+		Code:    "6666",
+		Added:   []string{"2022-01-04"},
+		Removed: []string{"2021-12-30"},
+		Moved:   []*dateMove{{From: "2021-12-29", To: "2021-12-31"}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("%+v != %+v", got, expected)
+	}
+	if got = diffDates("6666", current, current, "2021-12-28"); got != nil {
+		t.Fatalf("Unexpected change %+v", got)
+	}
+}
+
+func TestDetectChanges(t *testing.T) {
+	snapshot := snapshotT{}
+	cal := calendarTFixture()
+	if changes := snapshot.detectChanges([]*calendarT{cal}); changes != nil {
+		t.Fatalf("Unexpected changes on first run %+v", changes)
+	}
+	if len(snapshot["6666"]) != len(cal.dates) {
+		t.Fatalf("Unexpected snapshot %v", snapshot)
+	}
+	cal.dates = cal.dates[:len(cal.dates)-1]
+	changes := snapshot.detectChanges([]*calendarT{cal})
+	if len(changes) != 1 || !reflect.DeepEqual(changes[0].Removed, []string{"2022-01-03"}) {
+		t.Fatalf("Unexpected changes %+v", changes)
+	}
+	expected := "Leveringsdagene for 6666 er endret:\n  fjernet mandag 2022-01-03\n"
+	if got := formatChanges(changes, cal.lang()); got != expected {
+		t.Fatalf("%q != %q", got, expected)
+	}
+}
+
+func TestFormatChanges(t *testing.T) {
+	got := formatChanges([]*scheduleChange{{
+		Code:  "6666",
+		Added: []string{"2022-01-04"},
+		Moved: []*dateMove{{From: "2021-12-29", To: "2021-12-31"}},
+	}}, languages["en"])
+	expected := "Delivery dates for 6666 changed:\n" +
+		"  added   Tuesday 2022-01-04\n" +
+		"  moved   Wednesday 2021-12-29 -> Friday 2021-12-31\n"
+	if got != expected {
+		t.Fatalf("\n%s\n!=\n%s", got, expected)
+	}
+}
+
+// webhookFixture returns a server decoding the payloads posted to it.
+func webhookFixture(t *testing.T, status int) (*httptest.Server, *[]*webhookPayload) {
+	var payloads []*webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, &payload)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &payloads
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	server, payloads := webhookFixture(t, http.StatusNoContent)
+	changes := []*scheduleChange{{Code: "6666", Added: []string{"2022-01-04"}}}
+	n, err := newNotifier(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = n.notify(context.Background(), changes, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*payloads) != 1 || (*payloads)[0].Text != "text" || !reflect.DeepEqual((*payloads)[0].Changes, changes) {
+		t.Fatalf("Unexpected payloads %+v", *payloads)
+	}
+
+	failing, _ := webhookFixture(t, http.StatusInternalServerError)
+	n, _ = newNotifier(failing.URL, failing.Client())
+	if err = n.notify(context.Background(), changes, "text"); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("Expected 500, got %v", err)
+	}
+}
+
+func TestCommandNotifier(t *testing.T) {
+	if _, err := exec.LookPath("cp"); err != nil {
+		t.Skip(err)
+	}
+	path := filepath.Join(t.TempDir(), "changes.txt")
+	n, err := newNotifier(notifyCommandPrefix+"cp /dev/stdin "+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = n.notify(context.Background(), nil, "text\n"); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := os.ReadFile(path); err != nil || string(bs) != "text\n" {
+		t.Fatalf("Unexpected %q: %v", bs, err)
+	}
+	n, _ = newNotifier(notifyCommandPrefix+"false", nil)
+	if err = n.notify(context.Background(), nil, "text\n"); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestRunJobNotify(t *testing.T) {
+	dir := t.TempDir()
+	server, payloads := webhookFixture(t, http.StatusOK)
+	snapshot := filepath.Join(dir, "snapshot.json")
+	if err := (snapshotT{"6666": {"2021-12-28", "2021-12-29"}}).write(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	args, err := parseArgs(commandLine(), []string{
+		"--code=6666", "--provider=static", "--date=2021-12-28", "--output", filepath.Join(dir, "6666.ics"),
+		"--snapshot", snapshot, "--notify", server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err = args.runJob(context.Background(), args.jobs[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*payloads) != 1 || !strings.HasPrefix((*payloads)[0].Text, "Leveringsdagene for 6666 er endret:") {
+		t.Fatalf("Expected one notification, got %+v", *payloads)
+	}
+	saved, err := readSnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved["6666"]) <= 2 {
+		t.Fatalf("Snapshot not updated: %v", saved)
+	}
+}
+
+func TestParseArgsNotifyErrors(t *testing.T) {
+	for _, a := range [][]string{
+		{"--notify", "stdout", "--output", "6666.ics"},
+		{"--snapshot", "snapshot.json"},
+		{"--snapshot", "snapshot.json", "--notify", "mail"},
+		{"--snapshot", "snapshot.json", "--notify", "command:"},
+		{"--snapshot", "snapshot.json", "--notify", "stdout"},
+	} {
+		if _, err := parseArgs(commandLine(), append([]string{"--code=6666", "--provider=static"}, a...)); err == nil {
+			t.Fatalf("Expected error for %v", a)
+		}
+	}
+}
+
+func TestParseArgsSnapshotWithSeveralJobs(t *testing.T) {
+	_, err := parseArgs(commandLine(), []string{
+		"--config", writeConfig(t, configFixture), "--snapshot", "snapshot.json", "--notify", "stdout",
+	})
+	if err == nil || !strings.Contains(err.Error(), "-snapshot") {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestFormatChangesLanguage(t *testing.T) {
+	changes := []*scheduleChange{{
+		Code:  "6666",
+		Added: []string{"2022-01-04"},
+		Moved: []*dateMove{{From: "2021-12-29", To: "2021-12-31"}},
+	}}
+	for lang, expected := range map[string]string{
+		"nb": "Leveringsdagene for 6666 er endret:\n  ny      tirsdag 2022-01-04\n  flyttet onsdag 2021-12-29 -> fredag 2021-12-31\n",
+		"nn": "Leveringsdagane for 6666 er endra:\n  ny     tysdag 2022-01-04\n  flytta onsdag 2021-12-29 -> fredag 2021-12-31\n",
+	} {
+		if got := formatChanges(changes, languages[lang]); got != expected {
+			t.Fatalf("%s: %q != %q", lang, got, expected)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// placeT is the place a postal code belongs to.
+type placeT struct {
+	City         string `json:"city"`
+	Municipality string `json:"municipality"`
+	County       string `json:"county"`
+}
+
+type postalCodeLookupT struct {
+	PostalCodes []*placeT `json:"postal_codes"`
+}
+
+// String returns the post town, followed by the municipality when it
+// has a different name.
+func (p *placeT) String() string {
+	if p.Municipality == "" || strings.EqualFold(p.City, p.Municipality) {
+		return p.City
+	}
+	return fmt.Sprintf("%s, %s", p.City, p.Municipality)
+}
+
+func lookupURL(base *url.URL, code *postalCodeT) *url.URL {
+	return base.JoinPath("address/api/no/postal-codes", code.code)
+}
+
+// placeNames looks up places with the Bring postal code API,
+// remembering the result per code.
+type placeNames struct {
+	client      *http.Client
+	credentials credentialsFunc
+	baseURL     *url.URL
+	mu          sync.Mutex
+	places      map[string]*placeLookup
+}
+
+// placeLookup is a lookup of one postal code, shared by everyone
+// asking for the code while it runs. place and err are set when done
+// is closed.
+type placeLookup struct {
+	done  chan struct{}
+	place *placeT
+	err   error
+}
+
+func newPlaceNames(client *http.Client, baseURL *url.URL, creds credentialsFunc) *placeNames {
+	return &placeNames{
+		client:      client,
+		credentials: creds,
+		baseURL:     baseURL,
+		places:      map[string]*placeLookup{},
+	}
+}
+
+// lookup returns the place of code. Only one request is made per
+// code, after waiting for limiter. Failed lookups are tried again by
+// the next call.
+func (p *placeNames) lookup(ctx context.Context, code *postalCodeT, limiter *rateLimiter) (*placeT, error) {
+	p.mu.Lock()
+	l, ok := p.places[code.code]
+	if !ok {
+		l = &placeLookup{done: make(chan struct{})}
+		p.places[code.code] = l
+	}
+	p.mu.Unlock()
+	if ok {
+		select {
+		case <-l.done:
+			return l.place, l.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if l.err = limiter.wait(ctx); l.err == nil {
+		l.place, l.err = p.fetch(ctx, code)
+	}
+	if l.err != nil {
+		p.mu.Lock()
+		delete(p.places, code.code)
+		p.mu.Unlock()
+	}
+	close(l.done)
+	return l.place, l.err
+}
+
+func (p *placeNames) fetch(ctx context.Context, code *postalCodeT) (*placeT, error) {
+	creds, err := p.credentials()
+	if err != nil {
+		return nil, err
+	}
+	body, _, err := apiGet(ctx, p.client, lookupURL(p.baseURL, code), creds)
+	if err != nil {
+		return nil, err
+	}
+	var data postalCodeLookupT
+	if err = json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("unable to parse JSON: %w", err)
+	}
+	if len(data.PostalCodes) == 0 {
+		return nil, fmt.Errorf("unknown postal code: %s", code)
+	}
+	return data.PostalCodes[0], nil
+}
+
+// enrich adds the place of the postal code to the calendar, looking it
+// up as fast as limiter allows. Failed lookups are logged, the
+// calendar is usable without a place.
+func (p *placeNames) enrich(ctx context.Context, cal *calendarT, limiter *rateLimiter) {
+	if p == nil {
+		return
+	}
+	if place, err := p.lookup(ctx, cal.code, limiter); err != nil {
+		log.Printf("%s: unable to look up place name: %s", cal.code, err)
+	} else {
+		cal.place = place
+	}
+}
+
+func (p *placeT) description(code *postalCodeT, lang *languageT) string {
+	if p.County == "" {
+		return fmt.Sprintf("%s %s %s, %s %s.", lang.placeDescription, code, p.City, p.Municipality, lang.municipality)
+	}
+	return fmt.Sprintf("%s %s %s, %s %s, %s.", lang.placeDescription, code, p.City, p.Municipality, lang.municipality, p.County)
+}
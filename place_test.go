@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type lookupBackend struct {
+	requests atomic.Int32
+}
+
+func (b *lookupBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.requests.Add(1)
+	if r.Header.Get("X-Mybring-API-Key") != "key" || r.Header.Get("X-Mybring-API-Uid") != "uid" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/address/api/no/postal-codes/6666":
+		_, _ = io.WriteString(w, `{"postal_codes":[{"postal_code":"6666","city":"TESTBYGD","municipality":"TESTKOMMUNE","county":"TESTFYLKE"}]}`)
+	case "/address/api/no/postal-codes/0150":
+		_, _ = io.WriteString(w, `{"postal_codes":[{"postal_code":"0150","city":"OSLO","municipality":"OSLO","county":"OSLO"}]}`)
+	default:
+		_, _ = io.WriteString(w, `{"postal_codes":[]}`)
+	}
+}
+
+func testPlaceNames(t *testing.T) (*placeNames, *lookupBackend) {
+	t.Setenv("POSTGANG_API_UID", "uid")
+	t.Setenv("POSTGANG_API_KEY", "key")
+	backend := &lookupBackend{}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	baseURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newPlaceNames(server.Client(), baseURL, envCredentials(credentials{})), backend
+}
+
+func TestPlaceNamesLookup(t *testing.T) {
+	places, backend := testPlaceNames(t)
+	for i := 0; i < 2; i++ {
+		place, err := places.lookup(context.Background(), postalCode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if place.String() != "TESTBYGD, TESTKOMMUNE" {
+			t.Fatalf("Unexpected place %s", place)
+		}
+	}
+	if n := backend.requests.Load(); n != 1 {
+		t.Fatalf("Expected 1 request, got %d", n)
+	}
+	oslo, _ := toPostalCode("0150")
+	if place, err := places.lookup(context.Background(), oslo, nil); err != nil || place.String() != "OSLO" {
+		t.Fatalf("Unexpected place %s, %v", place, err)
+	}
+}
+
+func TestPlaceNamesConcurrentLookups(t *testing.T) {
+	places, backend := testPlaceNames(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if place, err := places.lookup(context.Background(), postalCode(), nil); err != nil || place.City != "TESTBYGD" {
+				t.Errorf("Unexpected place %v, %v", place, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := backend.requests.Load(); n != 1 {
+		t.Fatalf("Expected 1 request, got %d", n)
+	}
+}
+
+func TestPlaceNamesRateLimit(t *testing.T) {
+	places, backend := testPlaceNames(t)
+	limiter := newRateLimiter(20)
+	oslo, _ := toPostalCode("0150")
+	start := time.Now()
+	for _, code := range []*postalCodeT{postalCode(), oslo, postalCode()} {
+		cal := calendarTFixture()
+		cal.code = code
+		places.enrich(context.Background(), cal, limiter)
+	}
+	if n := backend.requests.Load(); n != 2 {
+		t.Fatalf("Expected 2 requests, got %d", n)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("Expected about 50ms at 20 lookups per second, took %s", elapsed)
+	}
+}
+
+func TestPlaceNamesUnknown(t *testing.T) {
+	places, _ := testPlaceNames(t)
+	code, _ := toPostalCode("1")
+	if _, err := places.lookup(context.Background(), code, nil); err == nil {
+		t.Fatal("Expected error")
+	}
+	cal := calendarTFixture()
+	cal.code = code
+	places.enrich(context.Background(), cal, nil)
+	if cal.place != nil {
+		t.Fatalf("Unexpected place %s", cal.place)
+	}
+}
+
+func TestPlaceNamesEnrich(t *testing.T) {
+	places, _ := testPlaceNames(t)
+	cal := calendarTFixture()
+	places.enrich(context.Background(), cal, nil)
+	got := toVCalendar(cal).String()
+	for _, expected := range []string{
+		"SUMMARY:6666 TESTBYGD\\, TESTKOMMUNE: Posten kommer tirsdag 28.\r\n",
+		"LOCATION:6666 TESTBYGD\\, TESTKOMMUNE\r\n",
+		"DESCRIPTION:Postlevering i 6666 TESTBYGD\\, TESTKOMMUNE kommune\\, TESTFYLKE.\r\n",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("Expected %q in\n%s", expected, got)
+		}
+	}
+}
+
+func TestPlaceNamesDisabled(t *testing.T) {
+	cal := calendarTFixture()
+	var places *placeNames
+	places.enrich(context.Background(), cal, nil)
+	if cal.place != nil {
+		t.Fatal("Expected no place")
+	}
+}
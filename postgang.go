@@ -6,8 +6,10 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -15,8 +17,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/taasan/postgang/ical"
@@ -87,13 +92,8 @@ var weekdays = map[string]time.Weekday{
 
 var weekdayNames = reverseMap(weekdays)
 
-func dataURL(code *postalCodeT) *url.URL {
-	if u, err := url.Parse(fmt.Sprintf("https://api.bring.com/address/api/no/postal-codes/%s/mailbox-delivery-dates", code)); err != nil {
-		log.Print("Unable to parse URL")
-		panic(err)
-	} else {
-		return u
-	}
+func dataURL(base *url.URL, code *postalCodeT) *url.URL {
+	return base.JoinPath("address/api/no/postal-codes", code.code, "mailbox-delivery-dates")
 }
 
 func readData(now *time.Time, in io.Reader) (*postenResponseT, *time.Time, error) {
@@ -113,13 +113,44 @@ type credentials struct {
 	key string
 }
 
-func fetchData(postalCode *postalCodeT, timezone *time.Location, creds *credentials) (*postenResponseT, *time.Time, error) {
-	client := &http.Client{}
-	if req, err := http.NewRequest("GET", dataURL(postalCode).String(), http.NoBody); err != nil {
+// credentialsFunc returns the Bring API credentials.
+type credentialsFunc func() (*credentials, error)
+
+// envCredentials reads the Bring API credentials from the
+// environment, with values from defaults used for unset variables.
+func envCredentials(defaults credentials) credentialsFunc {
+	return func() (*credentials, error) {
+		uid := os.Getenv("POSTGANG_API_UID")
+		if uid == "" {
+			uid = defaults.uid
+		}
+		if uid == "" {
+			return nil, fmt.Errorf("POSTGANG_API_UID not set")
+		}
+		key := os.Getenv("POSTGANG_API_KEY")
+		if key == "" {
+			key = defaults.key
+		}
+		if key == "" {
+			return nil, fmt.Errorf("POSTGANG_API_KEY not set")
+		}
+		return &credentials{uid, key}, nil
+	}
+}
+
+const (
+	apiKeyHeader = "X-Mybring-API-Key"
+	apiUIDHeader = "X-Mybring-API-Uid"
+)
+
+// apiGet makes an authenticated GET request to the Bring API and
+// returns the body of a successful response.
+func apiGet(ctx context.Context, client *http.Client, u *url.URL, creds *credentials) ([]byte, http.Header, error) {
+	if req, err := http.NewRequestWithContext(ctx, "GET", u.String(), http.NoBody); err != nil {
 		return nil, nil, err
 	} else {
-		req.Header.Add("X-Mybring-API-Key", creds.key)
-		req.Header.Add("X-Mybring-API-Uid", creds.uid)
+		req.Header.Add(apiKeyHeader, creds.key)
+		req.Header.Add(apiUIDHeader, creds.uid)
 
 		if resp, err := client.Do(req); err != nil {
 			return nil, nil, err
@@ -131,58 +162,177 @@ func fetchData(postalCode *postalCodeT, timezone *time.Location, creds *credenti
 			if bodyBytes, err := io.ReadAll(resp.Body); err != nil {
 				return nil, nil, err
 			} else {
-				bodyString := string(bodyBytes)
-				var data postenResponseT
-				if err = json.NewDecoder(strings.NewReader(bodyString)).Decode(&data); err != nil {
-					return nil, nil, fmt.Errorf("unable to parse JSON: %w", err)
-				}
-				var now time.Time
-				if now, err = time.Parse(time.RFC1123, resp.Header.Get("date")); err != nil {
-					log.Println(err)
-					now = time.Now()
-				}
-				now = now.In(timezone)
-				return &data, &now, nil
+				return bodyBytes, resp.Header, nil
 			}
 		}
 	}
 }
 
+func fetchData(
+	ctx context.Context, client *http.Client, base *url.URL, postalCode *postalCodeT, timezone *time.Location, creds *credentials,
+) (*postenResponseT, *time.Time, error) {
+	if bodyBytes, header, err := apiGet(ctx, client, dataURL(base, postalCode), creds); err != nil {
+		return nil, nil, err
+	} else {
+		var data postenResponseT
+		if err = json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&data); err != nil {
+			return nil, nil, fmt.Errorf("unable to parse JSON: %w", err)
+		}
+		var now time.Time
+		if now, err = time.Parse(time.RFC1123, header.Get("date")); err != nil {
+			log.Println(err)
+			now = time.Now()
+		}
+		now = now.In(timezone)
+		return &data, &now, nil
+	}
+}
+
 type calendarT struct {
 	now      *time.Time
 	dates    []*CivilTime
 	prodID   string
 	hostname string
 	code     *postalCodeT
+	place    *placeT
+	language *languageT
+	summary  string
+	// alarms are triggers relative to the start of delivery days.
+	alarms []time.Duration
+	// window makes timed events when set.
+	window *windowT
+	// summaryTemplate and descriptionTemplate replace the summary
+	// and description when set.
+	summaryTemplate     *template.Template
+	descriptionTemplate *template.Template
+	// cancelled are dates removed since the last run, given as
+	// cancelled events.
+	cancelled []*CivilTime
+	// revisions are the revisions of events by UID, when state is
+	// kept.
+	revisions map[string]*eventState
+}
+
+func (cal *calendarT) lang() *languageT {
+	if cal.language == nil {
+		return languages[defaultLanguage]
+	}
+	return cal.language
+}
+
+func (cal *calendarT) summaryFormat() string {
+	if cal.summary == "" {
+		return cal.lang().summary
+	}
+	return cal.summary
+}
+
+func toProdID(codes ...*postalCodeT) string {
+	buf := make([]string, len(codes))
+	for i, x := range codes {
+		buf[i] = x.code
+	}
+	return fmt.Sprintf("-//Aasan//Aasan Go Postgang %s@%s//EN", strings.Join(buf, "+"), version)
 }
 
 func toCalendarT(now *time.Time, response *postenResponseT, hostname string, postalCode *postalCodeT) *calendarT {
 	return &calendarT{
 		dates:    response.DeliveryDates,
 		now:      now,
-		prodID:   fmt.Sprintf("-//Aasan//Aasan Go Postgang %s@%s//EN", postalCode, version),
+		prodID:   toProdID(postalCode),
 		hostname: hostname,
 		code:     postalCode,
 	}
 }
 
-func toVCalendar(cal *calendarT) *ical.Section {
-	buf := make([]*ical.VEvent, len(cal.dates))
-	for i, x := range cal.dates {
-		buf[i] = toVEvent(x, cal)
+// toVCalendar merges the calendars into one VCALENDAR. When more than
+// one calendar is given, the postal code is included in the UIDs to
+// keep them unique.
+func toVCalendar(cals ...*calendarT) *ical.Section {
+	return ical.Calendar(mergeCalendars(cals...))
+}
+
+// mergeCalendars returns the events of all calendars, with the latest
+// timestamp.
+func mergeCalendars(cals ...*calendarT) *ical.VCalendar {
+	var buf []*ical.VEvent
+	codes := make([]*postalCodeT, len(cals))
+	now := cals[0].now
+	for i, cal := range cals {
+		codes[i] = cal.code
+		if cal.now.After(*now) {
+			now = cal.now
+		}
+		for _, x := range cal.dates {
+			buf = append(buf, toVEvent(x, cal, len(cals) > 1))
+		}
+		for _, x := range cal.cancelled {
+			buf = append(buf, toVEvent(x, cal, len(cals) > 1).Cancel())
+		}
+	}
+	prodID := cals[0].prodID
+	if len(cals) > 1 {
+		prodID = toProdID(codes...)
+	}
+	return ical.NewVCalendar(prodID, now, buf...)
+}
+
+func eventUID(date *CivilTime, cal *calendarT, withCode bool) string {
+	day := date.time.Format("20060102")
+	if withCode {
+		return fmt.Sprintf("postgang-%s-%s@%s", cal.code, day, cal.hostname)
+	}
+	return fmt.Sprintf("postgang-%s@%s", day, cal.hostname)
+}
+
+// ownsUID reports whether uid is the UID eventUID gives one of the
+// dates of the calendar.
+func (cal *calendarT) ownsUID(uid string, withCode bool) bool {
+	prefix := "postgang-"
+	if withCode {
+		prefix += cal.code.code + "-"
+	}
+	day := strings.TrimPrefix(uid, prefix)
+	if day == uid || !strings.HasSuffix(day, "@"+cal.hostname) {
+		return false
 	}
-	return ical.Calendar(ical.NewVCalendar(cal.prodID, cal.now, buf...))
+	_, err := time.Parse("20060102", strings.TrimSuffix(day, "@"+cal.hostname))
+	return err == nil
 }
 
-func toVEvent(date *CivilTime, cal *calendarT) *ical.VEvent {
-	dayName := weekdayNames[date.time.Weekday()]
+func toVEvent(date *CivilTime, cal *calendarT, withCode bool) *ical.VEvent {
+	dayName := cal.lang().weekdayNames[date.time.Weekday()]
 	dayNum := date.time.Day()
-	return ical.NewVEvent(
-		fmt.Sprintf("postgang-%s@%s", date.time.Format("20060102"), cal.hostname),
-		baseURL,
-		fmt.Sprintf("%s: Posten kommer %s %d.", cal.code, dayName, dayNum),
-		date.time,
-	)
+	label := cal.code.String()
+	var location, description string
+	if cal.place != nil {
+		location = fmt.Sprintf("%s %s", cal.code, cal.place)
+		label = location
+		description = cal.place.description(cal.code, cal.lang())
+	}
+	summary := fmt.Sprintf(cal.summaryFormat(), label, dayName, dayNum)
+	if cal.summaryTemplate != nil || cal.descriptionTemplate != nil {
+		data := cal.eventData(date)
+		summary = renderTemplate(cal.summaryTemplate, data, summary)
+		description = renderTemplate(cal.descriptionTemplate, data, description)
+	}
+	event := ical.NewVEvent(eventUID(date, cal, withCode), baseURL, summary, date.time).
+		SetLocation(location).
+		SetDescription(description)
+	var windowStart time.Duration
+	if cal.window != nil {
+		event.SetTime(cal.window.on(date.time, timezone))
+		windowStart = cal.window.start
+	}
+	for _, trigger := range cal.alarms {
+		// Triggers are relative to the event start, alarms to the
+		// start of the day.
+		event.AddAlarm(ical.NewVAlarm(trigger-windowStart, cal.lang().alarm))
+	}
+	if revision, ok := cal.revisions[eventUID(date, cal, withCode)]; ok {
+		event.SetRevision(revision.Sequence, revision.LastModified)
+	}
+	return event
 }
 
 type postalCodeT struct {
@@ -193,6 +343,40 @@ func (c *postalCodeT) String() string {
 	return c.code
 }
 
+// postalCodesT is a flag.Value collecting postal codes from repeated
+// flags and comma separated lists.
+type postalCodesT []*postalCodeT
+
+func (c postalCodesT) String() string {
+	buf := make([]string, len(c))
+	for i, x := range c {
+		buf[i] = x.code
+	}
+	return strings.Join(buf, ",")
+}
+
+func (c *postalCodesT) Set(s string) error {
+	for _, x := range strings.Split(s, ",") {
+		postalCode, err := toPostalCode(strings.TrimSpace(x))
+		if err != nil {
+			return err
+		}
+		if !c.contains(postalCode) {
+			*c = append(*c, postalCode)
+		}
+	}
+	return nil
+}
+
+func (c postalCodesT) contains(code *postalCodeT) bool {
+	for _, x := range c {
+		if x.code == code.code {
+			return true
+		}
+	}
+	return false
+}
+
 func toPostalCode(s string) (*postalCodeT, error) {
 	if x, err := strconv.ParseUint(s, 10, 16); err != nil {
 		return nil, err
@@ -217,6 +401,19 @@ func copyFile(sourcePath string, dest io.Writer) error {
 	return nil
 }
 
+// resolveHostname returns name, or the system hostname if name is
+// empty.
+func resolveHostname(name string) string {
+	if name != "" {
+		return name
+	}
+	if hostname, err := os.Hostname(); err != nil {
+		return err.Error()
+	} else {
+		return hostname
+	}
+}
+
 func printVersionLine(wr io.Writer, key, value string) {
 	fmt.Fprintf(wr, "%-12s: %s", key, value)
 	fmt.Fprintln(wr)
@@ -240,89 +437,326 @@ func die(msg any) {
 }
 
 type commandLineArgs struct {
-	code       *postalCodeT
-	outputPath string
-	fetch      func() (*postenResponseT, *time.Time, error)
-	err        error
-	version    bool
-	hostname   string
+	jobs       []*jobT
+	provider   deliveryProvider
+	placeNames *placeNames
+	caldav     *caldavPublisher
+	// notifyClient sends webhook notifications.
+	notifyClient *http.Client
+	concurrency  int
+	// limiter is shared by the fetches and place name lookups of all
+	// jobs.
+	limiter *rateLimiter
+	timeout time.Duration
+	version bool
 }
 
 func parseArgs(cmd *flag.FlagSet, a []string) (commandLineArgs, error) {
 	var (
-		codeArg       string
-		outputPathArg string
-		versionArg    bool
-		inputPathArg  string
-		dateArg       string
-		hostnameArg   string
+		codesArg       postalCodesT
+		outputPathArg  string
+		versionArg     bool
+		inputPathArg   string
+		providerArg    string
+		dateArg        string
+		hostnameArg    string
+		languageArg    string
+		summaryArg     string
+		summaryTmplArg string
+		descTmplArg    string
+		alarmArgs      []string
+		windowArg      string
+		formatArg      string
+		caldavURLArg   string
+		stateArg       string
+		snapshotArg    string
+		notifyArgs     []string
+		configArg      string
+		placeNamesArg  bool
+		concurrencyArg int
+		rateArg        float64
+		timeoutArg     time.Duration
+		clientArgs     clientFlags
+		credentialArgs credentialFlags
+		caldavArgs     caldavFlags
 	)
-	cmd.StringVar(&inputPathArg, "input", "", "Read input from `file` instead of fetching from posten.no")
+	clientArgs.register(cmd)
+	credentialArgs.register(cmd)
+	caldavArgs.register(cmd)
+	cmd.StringVar(&configArg, "config", "", "Read settings and jobs from JSON `file`, flags take precedence")
+	cmd.StringVar(&inputPathArg, "input", "", "Read input from `file` instead of fetching from posten.no, - for standard input")
+	cmd.StringVar(&providerArg, "provider", "", "Fetch delivery dates with `provider`: "+strings.Join(providerNames(), ", "))
 	cmd.StringVar(&dateArg, "date", "", "Use as fetch `date`")
 	cmd.StringVar(&hostnameArg, "hostname", "", "Use in UID")
+	cmd.StringVar(&languageArg, "language", "", "Calendar `language`: "+strings.Join(languageNames(), ", "))
+	cmd.StringVar(&summaryArg, "summary", "", "Summary `format` taking postal code, weekday name and day of month")
+	cmd.StringVar(&summaryTmplArg, "summary-template", "", "Summary `template` in text/template syntax using "+templateFields)
+	cmd.StringVar(&descTmplArg, "description-template", "", "Description `template` in text/template syntax using "+templateFields)
+	cmd.Var(alarmFlag{alarms: &alarmArgs}, "alarm",
+		"Remind `duration` after the start of delivery days, like -PT5H for 19:00 the day before; may be repeated")
+	cmd.Var(alarmFlag{alarms: &alarmArgs, clock: true}, "alarm-at", "Remind at `HH:MM` on delivery days; may be repeated")
+	cmd.StringVar(&windowArg, "window", "", "Make timed events for the delivery window `HH:MM-HH:MM` instead of all-day events")
+	cmd.StringVar(&formatArg, "format", "", "Output `format`: "+strings.Join(formatNames(), ", ")+" (default ics)")
+	cmd.BoolVar(&placeNamesArg, "place-names", false, "Look up post town and municipality from the Bring API")
+	cmd.IntVar(&concurrencyArg, "concurrency", defaultConcurrency, "Fetch at most `count` postal codes at once")
+	cmd.Float64Var(&rateArg, "rate", 0, "Start at most `count` fetches and place name lookups per second, 0 for no limit")
+	cmd.DurationVar(&timeoutArg, "timeout", 0, "Give up after `duration`, 0 for no limit")
 	cmd.BoolVar(&versionArg, "version", false, "Show version and exit")
-	cmd.StringVar(&codeArg, "code", "", "Postal code, an `integer` between 1 and 9999")
+	cmd.Var(&codesArg, "code", "Postal code, an `integer` between 1 and 9999. Repeat or separate with commas for more codes")
 	cmd.StringVar(&outputPathArg, "output", "", "Path of output file")
+	cmd.StringVar(&stateArg, "state", "", "Keep published dates in `file` to cancel removed dates and revise changed events")
+	cmd.StringVar(&snapshotArg, "snapshot", "", "Keep delivery dates in `file` to notify about changes since the last run")
+	cmd.Var(notifyFlag{specs: &notifyArgs}, "notify",
+		"Send changes to `sink`: stdout, a webhook URL or "+notifyCommandPrefix+"COMMAND taking them on stdin; may be repeated")
+	cmd.StringVar(&caldavURLArg, "caldav-url", "", "Publish the events to the CalDAV collection at `URL` instead of writing output")
 	if err := cmd.Parse(a); err != nil {
 		return commandLineArgs{}, err
 	}
 	if versionArg {
 		return commandLineArgs{version: true}, nil
 	}
-	if postalCode, err := toPostalCode(codeArg); err != nil {
+	isSet := map[string]bool{}
+	cmd.Visit(func(f *flag.Flag) {
+		isSet[f.Name] = true
+	})
+	cfg := &configT{}
+	var err error
+	if configArg != "" {
+		if cfg, err = readConfig(configArg); err != nil {
+			return commandLineArgs{}, err
+		}
+	}
+	opts, err := clientArgs.providerOptions(&credentialArgs, credentials{uid: cfg.APIUID, key: cfg.APIKey})
+	if err != nil {
 		return commandLineArgs{}, err
-	} else {
-		var doFetch func() (*postenResponseT, *time.Time, error)
-		if inputPathArg != "" {
-			var in *os.File
-			if inputPathArg == "-" {
-				in = os.Stdin
-			} else {
-				if in, err = os.Open(inputPathArg); err != nil {
-					return commandLineArgs{}, err
-				}
-			}
-			var now time.Time
-			if dateArg != "" {
-				if now, err = time.Parse(time.DateOnly, dateArg); err != nil {
-					return commandLineArgs{}, err
-				}
-			} else {
-				now = time.Now()
-			}
+	}
+	opts.inputPath = inputPathArg
+	if dateArg != "" {
+		if now, err := time.Parse(time.DateOnly, dateArg); err != nil {
+			return commandLineArgs{}, err
+		} else {
 			now = now.In(timezone)
-			doFetch = func() (*postenResponseT, *time.Time, error) {
-				return readData(&now, in)
+			opts.now = &now
+		}
+	}
+	jobConfigs := cfg.Jobs
+	if isSet["code"] || len(jobConfigs) == 0 {
+		jobConfigs = []*jobConfig{{}}
+	}
+	if len(jobConfigs) > 1 && isSet["output"] {
+		return commandLineArgs{}, fmt.Errorf("-output can not be used with several jobs")
+	}
+	if len(jobConfigs) > 1 && isSet["caldav-url"] {
+		return commandLineArgs{}, fmt.Errorf("-caldav-url can not be used with several jobs")
+	}
+	if len(jobConfigs) > 1 && isSet["state"] {
+		return commandLineArgs{}, fmt.Errorf("-state can not be used with several jobs")
+	}
+	if len(jobConfigs) > 1 && isSet["snapshot"] {
+		return commandLineArgs{}, fmt.Errorf("-snapshot can not be used with several jobs")
+	}
+	flagJob := &jobConfig{
+		Code:                codesArg.String(),
+		Output:              outputPathArg,
+		Hostname:            hostnameArg,
+		Language:            languageArg,
+		Summary:             summaryArg,
+		SummaryTemplate:     summaryTmplArg,
+		DescriptionTemplate: descTmplArg,
+		Alarms:              alarmArgs,
+		Window:              windowArg,
+		Format:              formatArg,
+		CalDAV:              caldavURLArg,
+		State:               stateArg,
+		Snapshot:            snapshotArg,
+		Notify:              notifyArgs,
+	}
+	jobs := make([]*jobT, len(jobConfigs))
+	for i, jc := range jobConfigs {
+		job, err := flagJob.merge(jc.merge(&cfg.jobConfig)).toJob()
+		if err != nil {
+			return commandLineArgs{}, err
+		}
+		jobs[i] = job
+	}
+	if !isSet["provider"] {
+		providerArg = cfg.Provider
+	}
+	switch {
+	case providerArg != "":
+	case inputPathArg == "-":
+		providerArg = stdinProviderName
+	case inputPathArg != "":
+		providerArg = fileProviderName
+	default:
+		providerArg = bringProviderName
+	}
+	if providerArg == fileProviderName || providerArg == stdinProviderName {
+		for _, job := range jobs {
+			if len(job.codes) > 1 {
+				return commandLineArgs{}, fmt.Errorf("only one postal code can be used with provider %s", providerArg)
 			}
-		} else {
-			doFetch = func() (*postenResponseT, *time.Time, error) {
-				uid := os.Getenv("POSTGANG_API_UID")
-				if uid == "" {
-					return nil, nil, fmt.Errorf("POSTGANG_API_UID not set")
-				}
-				key := os.Getenv("POSTGANG_API_KEY")
-				if key == "" {
-					return nil, nil, fmt.Errorf("POSTGANG_API_KEY not set")
-				}
-				creds := &credentials{uid, key}
-				return fetchData(postalCode, timezone, creds)
+		}
+	}
+	provider, err := newProvider(providerArg, opts)
+	if err != nil {
+		return commandLineArgs{}, err
+	}
+	if !isSet["place-names"] {
+		placeNamesArg = cfg.PlaceNames
+	}
+	var places *placeNames
+	if placeNamesArg {
+		places = newPlaceNames(opts.client, opts.baseURL, opts.credentials)
+	}
+	var caldav *caldavPublisher
+	for _, job := range jobs {
+		if job.caldavURL != nil && caldav == nil {
+			if caldav, err = caldavArgs.publisher(&clientArgs, cfg); err != nil {
+				return commandLineArgs{}, err
 			}
 		}
-		if outputPathArg == "-" {
-			outputPathArg = ""
+	}
+	var notifyClient *http.Client
+	for _, job := range jobs {
+		if len(job.notify) > 0 && notifyClient == nil {
+			transport, err := clientArgs.transport()
+			if err != nil {
+				return commandLineArgs{}, err
+			}
+			notifyClient = &http.Client{Transport: transport, Timeout: clientArgs.timeout}
 		}
-		return commandLineArgs{
-			code:       postalCode,
-			fetch:      doFetch,
-			outputPath: outputPathArg,
-			version:    versionArg,
-			err:        err,
-			hostname:   hostnameArg,
-		}, nil
 	}
+	if concurrencyArg < 1 {
+		return commandLineArgs{}, fmt.Errorf("-concurrency must be at least 1")
+	}
+	if rateArg < 0 {
+		return commandLineArgs{}, fmt.Errorf("-rate can not be negative")
+	}
+	return commandLineArgs{
+		jobs:         jobs,
+		provider:     provider,
+		placeNames:   places,
+		caldav:       caldav,
+		notifyClient: notifyClient,
+		concurrency:  concurrencyArg,
+		limiter:      newRateLimiter(rateArg),
+		timeout:      timeoutArg,
+		version:      versionArg,
+	}, nil
 }
 
-func cli(as []string) {
+// writeOutput writes to the file at path, or standard output if path
+// is empty. The file is only replaced when write succeeds and ctx is
+// not done. The temporary file is always removed.
+func writeOutput(ctx context.Context, path string, write func(wr *bufio.Writer) error) error {
+	if path == "" {
+		buf := bufio.NewWriter(os.Stdout)
+		if err := write(buf); err != nil {
+			return err
+		}
+		return buf.Flush()
+	}
+	tmpFile, err := os.CreateTemp("", "postgang-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+	buf := bufio.NewWriter(tmpFile)
+	if err = write(buf); err != nil {
+		return err
+	}
+	if err = buf.Flush(); err != nil {
+		return err
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	outputDestination, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = copyFile(tmpFile.Name(), outputDestination); err != nil {
+		outputDestination.Close()
+		return err
+	}
+	return outputDestination.Close()
+}
+
+func (args *commandLineArgs) fetchCalendars(ctx context.Context, job *jobT) ([]*calendarT, error) {
+	hostname := resolveHostname(job.hostname)
+	results, err := fetchAll(ctx, args.provider, job.codes, args.concurrency, args.limiter)
+	if err != nil {
+		return nil, err
+	}
+	calendars := make([]*calendarT, len(job.codes))
+	for i, code := range job.codes {
+		calendars[i] = toCalendarT(results[i].now, results[i].response, hostname, code)
+		calendars[i].language = job.language
+		calendars[i].summary = job.summary
+		calendars[i].alarms = job.alarms
+		calendars[i].window = job.window
+		calendars[i].summaryTemplate = job.summaryTemplate
+		calendars[i].descriptionTemplate = job.descriptionTemplate
+		args.placeNames.enrich(ctx, calendars[i], args.limiter)
+	}
+	return calendars, nil
+}
+
+func (args *commandLineArgs) runJob(ctx context.Context, job *jobT) error {
+	calendars, err := args.fetchCalendars(ctx, job)
+	if err != nil {
+		return err
+	}
+	var state *scheduleState
+	if job.statePath != "" {
+		if state, err = readScheduleState(job.statePath); err != nil {
+			return err
+		}
+		if err = state.track(calendars); err != nil {
+			return err
+		}
+	}
+	if job.caldavURL != nil {
+		err = args.caldav.publishCalendars(ctx, job.caldavURL, calendars)
+	} else {
+		err = writeOutput(ctx, job.outputPath, func(wr *bufio.Writer) error {
+			return formats[job.format](ctx, wr, toVCalendar(calendars...))
+		})
+	}
+	if err != nil {
+		return err
+	}
+	// The state is only saved once the events are out.
+	if state != nil {
+		if err = state.write(job.statePath); err != nil {
+			return err
+		}
+	}
+	if job.snapshotPath != "" {
+		return args.notifyChanges(ctx, job, calendars)
+	}
+	return nil
+}
+
+func cli(ctx context.Context, as []string) {
+	if len(as) > 0 {
+		switch as[0] {
+		case "serve":
+			serveCli(ctx, as[1:])
+			return
+		case "fake-bring":
+			fakeBringCli(ctx, as[1:])
+			return
+		case "lint":
+			lintCli(ctx, as[1:])
+			return
+		case "recording-body":
+			recordingBodyCli(ctx, as[1:])
+			return
+		}
+	}
 	if args, err := parseArgs(flag.CommandLine, as); err != nil {
 		die(err)
 	} else {
@@ -330,54 +764,32 @@ func cli(as []string) {
 			printVersion(os.Stdout)
 			os.Exit(0)
 		}
-		wr := os.Stdout
-		ok := false
-		if args.outputPath != "" {
-			var tmpFile, outputDestination *os.File
-			if outputDestination, err = os.Create(args.outputPath); err != nil {
-				die(err)
-			}
-			if tmpFile, err = os.CreateTemp("", "postgang-"); err != nil {
-				die(err)
-			}
-			wr = tmpFile
-			defer func() {
-				if ok {
-					if err = copyFile(tmpFile.Name(), outputDestination); err != nil {
-						die(err)
-					}
-				}
-				os.Remove(tmpFile.Name())
-			}()
+		if args.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, args.timeout)
+			defer cancel()
 		}
-		var response *postenResponseT
-		var now *time.Time
-		if response, now, err = args.fetch(); err != nil {
-			die(err)
-		}
-		var hostname string
-		if args.hostname != "" {
-			hostname = args.hostname
-		} else {
-			if hostname, err = os.Hostname(); err != nil {
-				hostname = err.Error()
+		var errs []error
+		for _, job := range args.jobs {
+			if err = args.runJob(ctx, job); err != nil {
+				errs = append(errs, err)
 			}
 		}
-		calendar := toCalendarT(now, response, hostname, args.code)
-		if len(calendar.dates) == 0 {
-			die(fmt.Sprintf("No delivery days found, check postal code: %s", args.code))
-		}
-		buf := bufio.NewWriter(wr)
-		defer buf.Flush()
-
-		p := ical.NewContentPrinter(buf).Print(toVCalendar(calendar))
-		if err = p.Error(); err != nil {
+		if err = errors.Join(errs...); err != nil {
 			die(err)
 		}
-		ok = true // Used in closure
 	}
 }
 
+// signalContext returns a context canceled on SIGINT or SIGTERM.
+// Signals are intercepted until stop is called, so later signals do
+// not kill the process before it has cleaned up.
+func signalContext() (ctx context.Context, stop context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
 func main() {
-	cli(os.Args[1:])
+	ctx, stop := signalContext()
+	defer stop()
+	cli(ctx, os.Args[1:])
 }
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/taasan/postgang/ical"
 )
 
 // TestHelloName calls greetings.Hello with a name, checking
@@ -39,6 +47,15 @@ func prodID() string {
 	return fmt.Sprintf("-//Aasan//Aasan Go Postgang %s@%s//EN", postalCode(), version)
 }
 
+func TestToProdIDMultipleCodes(t *testing.T) {
+	other, _ := toPostalCode("1234")
+	got := toProdID(postalCode(), other)
+	expected := fmt.Sprintf("-//Aasan//Aasan Go Postgang 6666+1234@%s//EN", version)
+	if got != expected {
+		t.Fatalf("%s != %s", got, expected)
+	}
+}
+
 func postalCode() *postalCodeT {
 	postalCode, _ := toPostalCode("6666")
 	return postalCode
@@ -126,6 +143,45 @@ func TestPrint(t *testing.T) {
 	}
 }
 
+func TestParseFixtureRoundTrip(t *testing.T) {
+	icsFixture := readFixture("test/fixture.ics", t)
+	sections, err := ical.Parse(bytes.NewReader(icsFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	for _, section := range sections {
+		if err = ical.NewContentPrinter(&sb).Print(section).Error(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sb.String() != string(icsFixture) {
+		t.Fatalf("\n%s\n!=\n%s", sb.String(), icsFixture)
+	}
+}
+
+func TestMergedCalendar(t *testing.T) {
+	first := calendarTFixture()
+	second := calendarTFixture()
+	second.code, _ = toPostalCode("1234")
+	second.dates = second.dates[:1]
+	got := toVCalendar(first, second).String()
+	for _, expected := range []string{
+		"PRODID:-//Aasan//Aasan Go Postgang 6666+1234@" + version + "//EN\r\n",
+		"UID:postgang-6666-20211228@test\r\n",
+		"UID:postgang-1234-20211228@test\r\n",
+		"SUMMARY:6666: Posten kommer tirsdag 28.\r\n",
+		"SUMMARY:1234: Posten kommer tirsdag 28.\r\n",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("Expected %q in\n%s", expected, got)
+		}
+	}
+	if n := strings.Count(got, "BEGIN:VEVENT"); n != len(first.dates)+len(second.dates) {
+		t.Fatalf("Expected %d events, got %d", len(first.dates)+len(second.dates), n)
+	}
+}
+
 func commandLine() *flag.FlagSet {
 	return flag.NewFlagSet("Test", flag.ContinueOnError)
 }
@@ -135,9 +191,34 @@ func TestParseArgsCode(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected := commandLineArgs{code: postalCode()}
-	if !reflect.DeepEqual(got.code, expected.code) {
-		t.Fatalf("%s != %s", got.code, expected.code)
+	expected := postalCodesT{postalCode()}
+	if !reflect.DeepEqual(got.jobs[0].codes, expected) {
+		t.Fatalf("%s != %s", got.jobs[0].codes, expected)
+	}
+}
+
+func TestParseArgsMultipleCodes(t *testing.T) {
+	got, err := parseArgs(commandLine(), []string{"--code=6666,1234", "--code", "42", "--code=1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "6666,1234,0042"
+	if got.jobs[0].codes.String() != expected {
+		t.Fatalf("%s != %s", got.jobs[0].codes, expected)
+	}
+}
+
+func TestParseArgsNoCode(t *testing.T) {
+	_, err := parseArgs(commandLine(), []string{})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestParseArgsMultipleCodesWithInput(t *testing.T) {
+	_, err := parseArgs(commandLine(), []string{"--code=6666,1234", "--input=-"})
+	if err == nil {
+		t.Fatal("Expected error")
 	}
 }
 
@@ -161,11 +242,11 @@ func TestParseArgsVersion(t *testing.T) {
 		t.Fatal(err)
 	}
 	expected := commandLineArgs{version: true}
-	if !reflect.DeepEqual(got.code, expected.code) {
-		t.Fatalf("%s != %s", got.code, expected.code)
+	if !reflect.DeepEqual(got.jobs, expected.jobs) {
+		t.Fatalf("%v != %v", got.jobs, expected.jobs)
 	}
-	if got.code != nil {
-		t.Fatalf("I didn't expect a code! %s", got.code)
+	if got.jobs != nil {
+		t.Fatalf("I didn't expect a code! %v", got.jobs)
 	}
 }
 
@@ -206,7 +287,7 @@ func TestCli(t *testing.T) {
 	}
 	os.Stdout = stdout.out
 	var outputBuf bytes.Buffer
-	cli([]string{"--code", postalCode().code, "--input=-", "--date", now().Format(time.DateOnly), "--hostname", "test"})
+	cli(context.Background(), []string{"--code", postalCode().code, "--input=-", "--date", now().Format(time.DateOnly), "--hostname", "test"})
 	os.Stdin = stdin.orig
 	stdout.out.Close()
 	_, err = io.Copy(&outputBuf, stdout.in)
@@ -222,8 +303,48 @@ func TestCli(t *testing.T) {
 	}
 }
 
+func TestWriteOutputCanceled(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	output := filepath.Join(t.TempDir(), "out.ics")
+	ctx, cancel := context.WithCancel(context.Background())
+	err := writeOutput(ctx, output, func(wr *bufio.Writer) error {
+		_, err := wr.WriteString("BEGIN:VCALENDAR")
+		cancel()
+		return err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, err = os.Stat(output); !os.IsNotExist(err) {
+		t.Fatalf("Expected no output file, got %v", err)
+	}
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+		t.Fatalf("Temporary files left behind: %v", entries)
+	}
+}
+
+func TestRunJobCanceled(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	output := filepath.Join(t.TempDir(), "out.ics")
+	got, err := parseArgs(commandLine(), []string{"--code=6666", "--provider=static", "--output", output, "--timeout=1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.timeout != time.Second {
+		t.Fatalf("Unexpected timeout %s", got.timeout)
+	}
+	if err = got.runJob(canceledContext(), got.jobs[0]); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, err = os.Stat(output); !os.IsNotExist(err) {
+		t.Fatalf("Expected no output file, got %v", err)
+	}
+}
+
 func TestDataURL(t *testing.T) { //nolint
-	dataURL(postalCode())
+	dataURL(bringAPIURL, postalCode())
 }
 
 func TestToPostalCode(t *testing.T) {
@@ -246,3 +367,21 @@ func TestToPostalCodeOutOfRange(t *testing.T) {
 		}
 	}
 }
+
+func TestSignalContextKeepsIntercepting(t *testing.T) {
+	ctx, stop := signalContext()
+	defer stop()
+	for i := 0; i < 2; i++ {
+		if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected context to be canceled")
+	}
+	// Give a second signal time to kill the process, had it not been
+	// intercepted.
+	time.Sleep(50 * time.Millisecond)
+}
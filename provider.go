@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// deliveryProvider fetches delivery dates for a postal code. The
+// returned time is when the data was fetched. fetch should give up
+// when ctx is done.
+type deliveryProvider interface {
+	fetch(ctx context.Context, code *postalCodeT) (*postenResponseT, *time.Time, error)
+}
+
+// deliveryProviderFunc adapts a function to a deliveryProvider.
+type deliveryProviderFunc func(ctx context.Context, code *postalCodeT) (*postenResponseT, *time.Time, error)
+
+func (f deliveryProviderFunc) fetch(ctx context.Context, code *postalCodeT) (*postenResponseT, *time.Time, error) {
+	return f(ctx, code)
+}
+
+// providerOptions are the settings a providerFactory may use.
+type providerOptions struct {
+	// inputPath is the file to read, "-" for standard input.
+	inputPath string
+	// now is the fetch date for providers without one of their own.
+	now *time.Time
+	// client is the HTTP client to use for network requests.
+	client *http.Client
+	// baseURL is the base URL of the Bring API.
+	baseURL *url.URL
+	// credentials returns the Bring API credentials.
+	credentials credentialsFunc
+}
+
+// providerFactory creates a deliveryProvider.
+type providerFactory func(opts *providerOptions) (deliveryProvider, error)
+
+const (
+	bringProviderName  = "bring"
+	fileProviderName   = "file"
+	stdinProviderName  = "stdin"
+	staticProviderName = "static"
+)
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]providerFactory{
+		bringProviderName:  newBringProvider,
+		fileProviderName:   newFileProvider,
+		stdinProviderName:  newStdinProvider,
+		staticProviderName: newStaticProvider,
+	}
+)
+
+// registerProvider makes a deliveryProvider available by name. It
+// panics if the name is already registered.
+func registerProvider(name string, factory providerFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if _, ok := providers[name]; ok {
+		panic(fmt.Sprintf("provider %s already registered", name))
+	}
+	providers[name] = factory
+}
+
+func providerNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newProvider(name string, opts *providerOptions) (deliveryProvider, error) {
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q, expected one of: %s", name, strings.Join(providerNames(), ", "))
+	}
+	return factory(opts)
+}
+
+// bringProvider fetches delivery dates from the Bring API. Postal codes are checked against
+// the postal code register before calling the API.
+type bringProvider struct {
+	client      *http.Client
+	baseURL     *url.URL
+	credentials credentialsFunc
+	register    *postalRegister
+}
+
+func newBringProvider(opts *providerOptions) (deliveryProvider, error) {
+	client := opts.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	creds := opts.credentials
+	if creds == nil {
+		creds = envCredentials(credentials{})
+	}
+	baseURL := opts.baseURL
+	if baseURL == nil {
+		baseURL = bringAPIURL
+	}
+	return &bringProvider{client: client, baseURL: baseURL, credentials: creds, register: embeddedRegister()}, nil
+}
+
+func (p *bringProvider) fetch(ctx context.Context, code *postalCodeT) (*postenResponseT, *time.Time, error) {
+	if err := p.register.validate(code); err != nil {
+		return nil, nil, err
+	}
+	creds, err := p.credentials()
+	if err != nil {
+		return nil, nil, err
+	}
+	return fetchData(ctx, p.client, p.baseURL, code, timezone, creds)
+}
+
+// fileProvider reads delivery dates from a JSON file in the format of
+// the Bring API.
+type fileProvider struct {
+	path string
+	now  *time.Time
+}
+
+func newFileProvider(opts *providerOptions) (deliveryProvider, error) {
+	if opts.inputPath == "" {
+		return nil, fmt.Errorf("provider %s needs an input file", fileProviderName)
+	}
+	return &fileProvider{path: opts.inputPath, now: opts.now}, nil
+}
+
+func (p *fileProvider) fetch(ctx context.Context, _ *postalCodeT) (*postenResponseT, *time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	in, err := os.Open(p.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer in.Close()
+	return readData(nowOrDefault(p.now), in)
+}
+
+// readerProvider reads delivery dates in the format of the Bring API
+// from a reader. The reader is consumed on the first call, later
+// calls return the same data.
+type readerProvider struct {
+	reader io.Reader
+	now    *time.Time
+	once   sync.Once
+	data   []byte
+	err    error
+}
+
+func newStdinProvider(opts *providerOptions) (deliveryProvider, error) {
+	return &readerProvider{reader: os.Stdin, now: opts.now}, nil
+}
+
+func newStaticProvider(opts *providerOptions) (deliveryProvider, error) {
+	return &readerProvider{reader: bytes.NewReader(staticFixture), now: opts.now}, nil
+}
+
+func (p *readerProvider) fetch(ctx context.Context, _ *postalCodeT) (*postenResponseT, *time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	p.once.Do(func() {
+		p.data, p.err = io.ReadAll(p.reader)
+	})
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	return readData(nowOrDefault(p.now), bytes.NewReader(p.data))
+}
+
+// staticFixture is served by the static provider, for demos and
+// testing.
+//
+//go:embed test/fixture.json
+var staticFixture []byte
+
+func nowOrDefault(now *time.Time) *time.Time {
+	if now != nil {
+		return now
+	}
+	n := time.Now().In(timezone)
+	return &n
+}
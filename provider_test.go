@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewProviderUnknown(t *testing.T) {
+	_, err := newProvider("nope", &providerOptions{})
+	if err == nil || !strings.Contains(err.Error(), "bring, file, static, stdin") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestRegisterProviderTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected panic")
+		}
+	}()
+	registerProvider(staticProviderName, newStaticProvider)
+}
+
+func TestRegisterProvider(t *testing.T) {
+	name := "test-register-provider"
+	registerProvider(name, func(opts *providerOptions) (deliveryProvider, error) {
+		return deliveryProviderFunc(func(context.Context, *postalCodeT) (*postenResponseT, *time.Time, error) {
+			return &postenResponseT{}, opts.now, nil
+		}), nil
+	})
+	defer func() {
+		providersMu.Lock()
+		delete(providers, name)
+		providersMu.Unlock()
+	}()
+	got, err := parseArgs(commandLine(), []string{"--code=6666", "--provider", name, "--date", "2021-12-28"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fetched, err := got.provider.fetch(context.Background(), postalCode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched.Format(time.DateOnly) != "2021-12-28" {
+		t.Fatalf("Unexpected date %s", fetched)
+	}
+}
+
+func TestStaticProvider(t *testing.T) {
+	provider, err := newProvider(staticProviderName, &providerOptions{now: now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		got, _, err := provider.fetch(context.Background(), postalCode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, dataFixture(t)) {
+			t.Fatalf("\n%+v\n\n!=\n\n%+v", got, dataFixture(t))
+		}
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := os.WriteFile(path, readFixture("test/fixture.json", t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseArgs(commandLine(), []string{"--code=6666", "--input", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := got.provider.fetch(context.Background(), postalCode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dataFixture(t)) {
+		t.Fatalf("\n%+v\n\n!=\n\n%+v", data, dataFixture(t))
+	}
+	if _, err = newProvider(fileProviderName, &providerOptions{}); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestFileProviderMissingFile(t *testing.T) {
+	provider, err := newProvider(fileProviderName, &providerOptions{inputPath: filepath.Join(t.TempDir(), "missing.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = provider.fetch(context.Background(), postalCode()); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestBringProviderWithoutCredentials(t *testing.T) {
+	t.Setenv("POSTGANG_API_UID", "")
+	provider, err := newProvider(bringProviderName, &providerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = provider.fetch(context.Background(), postalCode()); err == nil || err.Error() != "POSTGANG_API_UID not set" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
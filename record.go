@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// secretHeaders are left out of recordings.
+var secretHeaders = []string{apiKeyHeader, apiUIDHeader, "Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// conditionalHeaders are removed from requests while recording. A
+// 304 Not Modified answer is of no use to a replay without the cache.
+var conditionalHeaders = []string{"If-None-Match", "If-Modified-Since"}
+
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+}
+
+type recordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// exchange is a recorded HTTP request and its response.
+type exchange struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+func withoutSecrets(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range secretHeaders {
+		header.Del(name)
+	}
+	return header
+}
+
+func recordingURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	return u.String()
+}
+
+// recordTransport is a http.RoundTripper saving every exchange in
+// dir, one file per URL, with credentials removed. A later exchange
+// with the same URL replaces the earlier one. Requests are sent
+// unconditional, so the recorded response is complete.
+type recordTransport struct {
+	dir       string
+	transport http.RoundTripper
+}
+
+// unconditional returns req without conditionalHeaders.
+func unconditional(req *http.Request) *http.Request {
+	clone := req
+	for _, name := range conditionalHeaders {
+		if clone.Header.Get(name) != "" {
+			if clone == req {
+				clone = req.Clone(req.Context())
+			}
+			clone.Header.Del(name)
+		}
+	}
+	return clone
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = unconditional(req)
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		log.Printf("Not recording %s response for %s", resp.Status, recordingURL(req))
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	bs, err := json.MarshalIndent(&exchange{
+		Request: recordedRequest{
+			Method: req.Method,
+			URL:    recordingURL(req),
+			Header: withoutSecrets(req.Header),
+		},
+		Response: recordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     withoutSecrets(resp.Header),
+			Body:       string(body),
+		},
+	}, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(t.dir, requestFileName(req)), append(bs, '\n'))
+	}
+	if err != nil {
+		log.Printf("Unable to record response: %s", err)
+	}
+	return resp, nil
+}
+
+// replayTransport is a http.RoundTripper answering requests with the
+// exchanges saved by recordTransport, without network access. The
+// recorded Date header decides which delivery dates are upcoming, so
+// a replay gives the calendar of the recorded run.
+type replayTransport struct {
+	dir string
+}
+
+func readRecording(path string) (*exchange, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var recorded exchange
+	if err = json.Unmarshal(bs, &recorded); err != nil {
+		return nil, fmt.Errorf("broken recording %s: %w", path, err)
+	}
+	return &recorded, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	recorded, err := readRecording(filepath.Join(t.dir, requestFileName(req)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recording of %s %s in %s", req.Method, recordingURL(req), t.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if recorded.Request.Method != req.Method || recorded.Request.URL != recordingURL(req) {
+		return nil, fmt.Errorf("no recording of %s %s in %s", req.Method, recordingURL(req), t.dir)
+	}
+	if recorded.Response.Header == nil {
+		recorded.Response.Header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Response.StatusCode, http.StatusText(recorded.Response.StatusCode)),
+		StatusCode:    recorded.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Response.Header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(recorded.Response.Body))),
+		ContentLength: int64(len(recorded.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// writeRecordingBody writes the response body of the recording in path
+// to w, as the API sent it.
+func writeRecordingBody(path string, w io.Writer) error {
+	recorded, err := readRecording(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, recorded.Response.Body)
+	return err
+}
+
+func recordingBodyCli(_ context.Context, as []string) {
+	cmd := flag.NewFlagSet("recording-body", flag.ExitOnError)
+	cmd.Usage = func() {
+		fmt.Fprintf(cmd.Output(), "Usage: %s recording-body file\n\n", os.Args[0])
+		fmt.Fprintln(cmd.Output(), "Write the response body of a recording made by -record to standard output.")
+		fmt.Fprintln(cmd.Output(), "A delivery dates response can be used with -input or as a fake-bring fixture.")
+		cmd.PrintDefaults()
+	}
+	if err := cmd.Parse(as); err != nil {
+		die(err)
+	}
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if err := writeRecordingBody(cmd.Arg(0), os.Stdout); err != nil {
+		die(err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	t.Setenv(apiUIDEnv, "secret-uid")
+	t.Setenv(apiKeyEnv, "secret-key")
+	dir := t.TempDir()
+	server := httptest.NewServer(&fakeBringHandler{days: 14, faults: faultsT{}, now: func() time.Time { return *now() }})
+	recorder, err := parseArgs(commandLine(), []string{"--code=6666", "--api-url", server.URL, "--record", dir, "--user-agent", "test/1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, recordedNow, err := recorder.provider.fetch(context.Background(), postalCode())
+	server.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v: %v", files, err)
+	}
+	bs, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-uid", "secret-key"} {
+		if strings.Contains(string(bs), secret) {
+			t.Fatalf("Recording contains %s:\n%s", secret, bs)
+		}
+	}
+	recording, err := readRecording(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recording.Request.Header.Get("User-Agent"); got != "test/1.0" {
+		t.Fatalf("Expected the User-Agent sent, got %q", got)
+	}
+
+	player, err := parseArgs(commandLine(), []string{"--code=6666", "--api-url", server.URL, "--replay", dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, replayedNow, err := player.provider.fetch(context.Background(), postalCode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, recorded) || !replayedNow.Equal(*recordedNow) {
+		t.Fatalf("\n%+v %s\n\n!=\n\n%+v %s", replayed, replayedNow, recorded, recordedNow)
+	}
+	other, _ := toPostalCode("1234")
+	if _, _, err = player.provider.fetch(context.Background(), other); err == nil || !strings.Contains(err.Error(), "no recording") {
+		t.Fatalf("Expected missing recording, got %v", err)
+	}
+}
+
+func TestRecordAndReplayExclusive(t *testing.T) {
+	if _, err := (&clientFlags{recordDir: "a", replayDir: "b"}).client(); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestWriteRecordingBody(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(&fakeBringHandler{days: 14, faults: faultsT{}, now: func() time.Time { return *now() }})
+	defer server.Close()
+	recorder, err := parseArgs(commandLine(), []string{
+		"--code=6666", "--api-url", server.URL, "--record", dir, "--api-uid-file", writeSecret(t, filepath.Join(dir, "uid"), "uid", 0o600),
+		"--api-key-file", writeSecret(t, filepath.Join(dir, "key"), "key", 0o600),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, _, err := recorder.provider.fetch(context.Background(), postalCode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v", files)
+	}
+	var buf bytes.Buffer
+	if err = writeRecordingBody(files[0], &buf); err != nil {
+		t.Fatal(err)
+	}
+	fixture, _, err := readData(now(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fixture.DeliveryDates, recorded.DeliveryDates) {
+		t.Fatalf("\n%+v\n\n!=\n\n%+v", fixture, recorded)
+	}
+	if err = writeRecordingBody(filepath.Join(dir, "missing.json"), &buf); err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestRecordWithCache(t *testing.T) {
+	backend := &cacheBackend{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	recordDir := t.TempDir()
+	recorder, err := (&clientFlags{cacheDir: t.TempDir(), cacheMaxAge: time.Hour, recordDir: recordDir}).client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := getBody(t, recorder, server.URL+"/6666")
+	if second := getBody(t, recorder, server.URL+"/6666"); second != first {
+		t.Fatalf("%s != %s", second, first)
+	}
+	if backend.requests != 2 || backend.conditional != 0 {
+		t.Fatalf("Expected 2 unconditional requests, got %d, %d conditional", backend.requests, backend.conditional)
+	}
+	player, err := (&clientFlags{replayDir: recordDir}).client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed := getBody(t, player, server.URL+"/6666"); replayed != first {
+		t.Fatalf("%s != %s", replayed, first)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Categories in the postal code register.
+const (
+	categoryBoth     = "B" // Street addresses and PO boxes
+	categoryMultiple = "F" // Multiple uses
+	categoryStreet   = "G" // Street addresses
+	categoryPOBox    = "P" // PO boxes only
+	categoryService  = "S" // Service code, no deliveries
+)
+
+const maxSuggestions = 5
+
+var (
+	errInvalidPostalCode = errors.New("invalid postal code")
+	errEmptyRegister     = errors.New("postal code register is empty, run make update-register")
+)
+
+type registerEntry struct {
+	code         string
+	city         string
+	municipality string
+	category     string
+}
+
+func (e *registerEntry) String() string {
+	return fmt.Sprintf("%s %s", e.code, e.city)
+}
+
+// postalRegister is Posten's register of Norwegian postal codes.
+type postalRegister struct {
+	entries map[string]*registerEntry
+	codes   []string
+}
+
+//go:embed data/postnummerregister.txt
+var registerData []byte
+
+var (
+	embeddedRegisterOnce sync.Once
+	embeddedRegisterData *postalRegister
+)
+
+// embeddedRegister returns the postal code register compiled into the
+// binary.
+func embeddedRegister() *postalRegister {
+	embeddedRegisterOnce.Do(func() {
+		var err error
+		if embeddedRegisterData, err = parseRegister(bytes.NewReader(registerData)); err != nil {
+			panic(err)
+		}
+	})
+	return embeddedRegisterData
+}
+
+func parseRegister(r io.Reader) (*postalRegister, error) {
+	register := &postalRegister{entries: map[string]*registerEntry{}}
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("postal code register line %d: expected 5 fields, got %d", lineNo, len(fields))
+		}
+		code, err := toPostalCode(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("postal code register line %d: %w", lineNo, err)
+		}
+		register.entries[code.code] = &registerEntry{
+			code:         code.code,
+			city:         fields[1],
+			municipality: fields[3],
+			category:     fields[4],
+		}
+		register.codes = append(register.codes, code.code)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(register.codes)
+	return register, nil
+}
+
+// validate checks that the postal code exists and gets mailbox
+// deliveries.
+func (r *postalRegister) validate(code *postalCodeT) error {
+	if len(r.entries) == 0 {
+		return errEmptyRegister
+	}
+	entry, ok := r.entries[code.code]
+	if !ok {
+		if suggestions := r.suggest(code); len(suggestions) > 0 {
+			return fmt.Errorf("%w: %s does not exist, did you mean %s?", errInvalidPostalCode, code, strings.Join(suggestions, ", "))
+		}
+		return fmt.Errorf("%w: %s does not exist", errInvalidPostalCode, code)
+	}
+	switch entry.category {
+	case categoryPOBox:
+		return fmt.Errorf("%w: %s is for PO boxes only, no mailbox deliveries", errInvalidPostalCode, entry)
+	case categoryService:
+		return fmt.Errorf("%w: %s is a service code, no mailbox deliveries", errInvalidPostalCode, entry)
+	}
+	return nil
+}
+
+// suggest returns existing postal codes close to code: codes with
+// one digit changed or two adjacent digits swapped, or else the
+// nearest codes before and after.
+func (r *postalRegister) suggest(code *postalCodeT) []string {
+	var buf []string
+	for _, c := range r.codes {
+		if len(buf) == maxSuggestions {
+			break
+		}
+		if isTypo(code.code, c) {
+			buf = append(buf, r.entries[c].String())
+		}
+	}
+	if len(buf) > 0 {
+		return buf
+	}
+	i := sort.SearchStrings(r.codes, code.code)
+	if i > 0 {
+		buf = append(buf, r.entries[r.codes[i-1]].String())
+	}
+	if i < len(r.codes) {
+		buf = append(buf, r.entries[r.codes[i]].String())
+	}
+	return buf
+}
+
+func isTypo(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var diff []int
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			diff = append(diff, i)
+		}
+	}
+	switch len(diff) {
+	case 1:
+		return true
+	case 2:
+		i, j := diff[0], diff[1]
+		return j == i+1 && a[i] == b[j] && a[j] == b[i]
+	}
+	return false
+}
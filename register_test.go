@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const registerFixture = `# Test register
+0010	OSLO	0301	OSLO	P
+0150	OSLO	0301	OSLO	G
+0151	OSLO	0301	OSLO	G
+0155	OSLO	0301	OSLO	B
+1234	TESTSTAD	1500	TESTKOMMUNE	G
+6666	TESTBYGD	1500	TESTKOMMUNE	G
+6667	TESTBYGD	1500	TESTKOMMUNE	F
+9999	TESTSERVICE	1500	TESTKOMMUNE	S
+`
+
+// TestMain makes the fixture the embedded register, the tests use
+// made up postal codes.
+func TestMain(m *testing.M) {
+	embeddedRegisterOnce.Do(func() {
+		var err error
+		if embeddedRegisterData, err = parseRegister(strings.NewReader(registerFixture)); err != nil {
+			panic(err)
+		}
+	})
+	os.Exit(m.Run())
+}
+
+func testRegister(t *testing.T) *postalRegister {
+	register, err := parseRegister(strings.NewReader(registerFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return register
+}
+
+func validateCode(t *testing.T, register *postalRegister, s string) error {
+	code, err := toPostalCode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return register.validate(code)
+}
+
+func TestRegisterValid(t *testing.T) {
+	register := testRegister(t)
+	for _, code := range []string{"0150", "0155", "6666", "6667"} {
+		if err := validateCode(t, register, code); err != nil {
+			t.Fatalf("%s: %s", code, err)
+		}
+	}
+}
+
+func TestRegisterNoMailboxDelivery(t *testing.T) {
+	register := testRegister(t)
+	for code, expected := range map[string]string{
+		"0010": "0010 OSLO is for PO boxes only",
+		"9999": "9999 TESTSERVICE is a service code",
+	} {
+		err := validateCode(t, register, code)
+		if !errors.Is(err, errInvalidPostalCode) || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: unexpected error %v", code, err)
+		}
+	}
+}
+
+func TestRegisterSuggestions(t *testing.T) {
+	register := testRegister(t)
+	for code, expected := range map[string]string{
+		"0152": "did you mean 0150 OSLO, 0151 OSLO, 0155 OSLO?",
+		"6676": "did you mean 6666 TESTBYGD, 6667 TESTBYGD?",
+		"0510": "did you mean 0010 OSLO, 0150 OSLO?",
+		"5000": "did you mean 1234 TESTSTAD, 6666 TESTBYGD?",
+	} {
+		err := validateCode(t, register, code)
+		if !errors.Is(err, errInvalidPostalCode) || !strings.HasSuffix(err.Error(), expected) {
+			t.Fatalf("%s: unexpected error %v", code, err)
+		}
+	}
+}
+
+func TestRegisterEmpty(t *testing.T) {
+	register, err := parseRegister(strings.NewReader("# Nothing here\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = validateCode(t, register, "1"); !errors.Is(err, errEmptyRegister) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestRegisterParseError(t *testing.T) {
+	for _, data := range []string{"0150\tOSLO\n", "abcd\tOSLO\t0301\tOSLO\tG\n"} {
+		if _, err := parseRegister(strings.NewReader(data)); err == nil {
+			t.Fatalf("Expected error for %q", data)
+		}
+	}
+}
+
+func TestEmbeddedRegister(t *testing.T) {
+	register, err := parseRegister(bytes.NewReader(registerData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The highest postal code in use is 9991.
+	if err = validateCode(t, register, "9999"); err == nil {
+		t.Fatal("Expected 9999 to be rejected")
+	}
+}
+
+func TestBringProviderValidates(t *testing.T) {
+	provider := &bringProvider{register: testRegister(t)}
+	code, _ := toPostalCode("0010")
+	_, _, err := provider.fetch(context.Background(), code)
+	if !errors.Is(err, errInvalidPostalCode) {
+		t.Fatalf("Unexpected error %v", err)
+	}
+}
+
+func TestServeInvalidPostalCode(t *testing.T) {
+	register := testRegister(t)
+	server := serveFixture(t, deliveryProviderFunc(func(_ context.Context, code *postalCodeT) (*postenResponseT, *time.Time, error) {
+		return nil, nil, register.validate(code)
+	}))
+	resp, err := server.Client().Get(server.URL + "/0010.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
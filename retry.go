@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAttempts      = 4
+	defaultRetryDeadline = 2 * time.Minute
+	retryBaseDelay       = 500 * time.Millisecond
+	retryMaxDelay        = 30 * time.Second
+)
+
+// retryTransport is a http.RoundTripper retrying idempotent requests
+// on connection errors, timeouts, 429 and 5xx responses with
+// exponential backoff and jitter. A Retry-After header from the server
+// takes precedence over the computed delay. Each attempt gets its own
+// timeout, so retries are not cut short by the first attempt.
+type retryTransport struct {
+	attempts  int
+	deadline  time.Duration
+	timeout   time.Duration
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	transport http.RoundTripper
+	now       func() time.Time
+	sleep     func(ctx context.Context, d time.Duration) error
+	jitter    func(d time.Duration) time.Duration
+}
+
+func newRetryTransport(attempts int, deadline, timeout time.Duration, transport http.RoundTripper) *retryTransport {
+	return &retryTransport{
+		attempts:  attempts,
+		deadline:  deadline,
+		timeout:   timeout,
+		baseDelay: retryBaseDelay,
+		maxDelay:  retryMaxDelay,
+		transport: transport,
+		now:       time.Now,
+		sleep:     sleepContext,
+		jitter:    equalJitter,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// equalJitter returns a random duration in [d/2, d).
+func equalJitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half))) //nolint:gosec
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// retryAfter parses a Retry-After header, either delay-seconds or a
+// HTTP-date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.baseDelay
+	for i := 1; i < attempt && d < t.maxDelay; i++ {
+		d *= 2
+	}
+	if d > t.maxDelay {
+		d = t.maxDelay
+	}
+	return t.jitter(d)
+}
+
+func (t *retryTransport) logAttempt(req *http.Request, attempt int, reason, outcome string) {
+	log.Printf("%s %s: attempt %d/%d failed: %s, %s", req.Method, req.URL, attempt, t.attempts, reason, outcome)
+}
+
+// cancelBody stops the timeout of an attempt when the response body is
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// attempt sends req once. The timeout also covers reading the body.
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.transport.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.attempt(req)
+	}
+	start := t.now()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.attempt(req)
+		var reason string
+		switch {
+		case err != nil:
+			if req.Context().Err() != nil {
+				return nil, err
+			}
+			reason = err.Error()
+		case isRetryableStatus(resp.StatusCode):
+			reason = resp.Status
+		default:
+			return resp, nil
+		}
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp, t.now()); ok {
+				delay = d
+			}
+		}
+		if attempt >= t.attempts {
+			if t.attempts > 1 {
+				t.logAttempt(req, attempt, reason, "giving up")
+			}
+			return resp, err
+		}
+		if elapsed := t.now().Sub(start); elapsed+delay > t.deadline {
+			t.logAttempt(req, attempt, reason, fmt.Sprintf("retry deadline %s exceeded", t.deadline))
+			return resp, err
+		}
+		t.logAttempt(req, attempt, reason, fmt.Sprintf("retrying in %s", delay))
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err = t.sleep(req.Context(), delay); err != nil {
+			return nil, fmt.Errorf("retry aborted: %w", err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type flakyBackend struct {
+	failures   int
+	status     int
+	retryAfter string
+	requests   int
+}
+
+func (b *flakyBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.requests++
+	if b.requests <= b.failures {
+		if b.retryAfter != "" {
+			w.Header().Set("Retry-After", b.retryAfter)
+		}
+		w.WriteHeader(b.status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func testRetryTransport(attempts int, delays *[]time.Duration) *retryTransport {
+	transport := newRetryTransport(attempts, time.Hour, 0, http.DefaultTransport)
+	transport.jitter = func(d time.Duration) time.Duration { return d }
+	transport.sleep = func(_ context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+	return transport
+}
+
+func doGet(t *testing.T, transport http.RoundTripper, u string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, u, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	backend := &flakyBackend{failures: 2, status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	var delays []time.Duration
+	resp := doGet(t, testRetryTransport(4, &delays), server.URL)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if backend.requests != 3 {
+		t.Fatalf("Expected 3 requests, got %d", backend.requests)
+	}
+	expected := []time.Duration{retryBaseDelay, 2 * retryBaseDelay}
+	if len(delays) != len(expected) || delays[0] != expected[0] || delays[1] != expected[1] {
+		t.Fatalf("Expected delays %v, got %v", expected, delays)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	backend := &flakyBackend{failures: 1, status: http.StatusTooManyRequests, retryAfter: "7"}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	var delays []time.Duration
+	doGet(t, testRetryTransport(4, &delays), server.URL)
+	if len(delays) != 1 || delays[0] != 7*time.Second {
+		t.Fatalf("Expected [7s], got %v", delays)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	backend := &flakyBackend{failures: 10, status: http.StatusInternalServerError}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	var delays []time.Duration
+	resp := doGet(t, testRetryTransport(3, &delays), server.URL)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected %d, got %d", http.StatusInternalServerError, resp.StatusCode)
+	}
+	if backend.requests != 3 {
+		t.Fatalf("Expected 3 requests, got %d", backend.requests)
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	backend := &flakyBackend{failures: 10, status: http.StatusTooManyRequests, retryAfter: "3600"}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	var delays []time.Duration
+	transport := testRetryTransport(5, &delays)
+	transport.deadline = time.Minute
+	resp := doGet(t, transport, server.URL)
+	if resp.StatusCode != http.StatusTooManyRequests || backend.requests != 1 {
+		t.Fatalf("Expected one %d, got %d requests with %d", http.StatusTooManyRequests, backend.requests, resp.StatusCode)
+	}
+}
+
+func TestRetryNotFound(t *testing.T) {
+	backend := &flakyBackend{failures: 10, status: http.StatusNotFound}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	var delays []time.Duration
+	doGet(t, testRetryTransport(4, &delays), server.URL)
+	if backend.requests != 1 {
+		t.Fatalf("Expected 1 request, got %d", backend.requests)
+	}
+}
+
+func TestRetryConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	u := server.URL
+	server.Close()
+	var delays []time.Duration
+	req, err := http.NewRequest(http.MethodGet, u, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testRetryTransport(3, &delays).RoundTrip(req) //nolint:bodyclose
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if len(delays) != 2 {
+		t.Fatalf("Expected 2 retries, got %d", len(delays))
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	done := make(chan struct{})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			<-done
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+	var delays []time.Duration
+	transport := testRetryTransport(3, &delays)
+	transport.timeout = 50 * time.Millisecond
+	if resp := doGet(t, transport, server.URL); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if len(delays) != 1 {
+		t.Fatalf("Expected 1 retry, got %d", len(delays))
+	}
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	now := time.Date(2021, 12, 28, 0, 0, 0, 0, time.UTC)
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", now.Add(time.Minute).Format(http.TimeFormat))
+	if d, ok := retryAfter(resp, now); !ok || d != time.Minute {
+		t.Fatalf("Expected 1m, got %s", d)
+	}
+	resp.Header.Set("Retry-After", "soon")
+	if _, ok := retryAfter(resp, now); ok {
+		t.Fatal("Expected failure")
+	}
+}
+
+func TestEqualJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := equalJitter(time.Second); d < time.Second/2 || d >= time.Second {
+			t.Fatalf("Out of range: %s", d)
+		}
+	}
+}
+
+func TestSleepContextCanceled(t *testing.T) {
+	if !errors.Is(sleepContext(canceledContext(), time.Hour), context.Canceled) {
+		t.Fatal("Expected context.Canceled")
+	}
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
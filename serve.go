@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/taasan/postgang/ical"
+)
+
+const (
+	icsSuffix       = ".ics"
+	shutdownTimeout = 10 * time.Second
+)
+
+// calendarHandler serves GET /{code}.ics with a freshly fetched
+// calendar for the postal code.
+type calendarHandler struct {
+	provider   deliveryProvider
+	placeNames *placeNames
+	hostname   string
+}
+
+func (h *calendarHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if strings.Contains(name, "/") || !strings.HasSuffix(name, icsSuffix) {
+		http.NotFound(w, r)
+		return
+	}
+	code, err := toPostalCode(strings.TrimSuffix(name, icsSuffix))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	response, now, err := h.provider.fetch(r.Context(), code)
+	if errors.Is(err, errInvalidPostalCode) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%s: %s", code, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	calendar := toCalendarT(now, response, h.hostname, code)
+	if len(calendar.dates) == 0 {
+		http.Error(w, fmt.Sprintf("No delivery days found, check postal code: %s", code), http.StatusNotFound)
+		return
+	}
+	h.placeNames.enrich(r.Context(), calendar, nil)
+	var buf bytes.Buffer
+	if err = ical.NewContentPrinter(&buf).PrintContext(r.Context(), toVCalendar(calendar)).Error(); err != nil {
+		log.Printf("%s: %s", code, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", name))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err = buf.WriteTo(w); err != nil {
+		log.Printf("%s: %s", code, err)
+	}
+}
+
+type serveArgs struct {
+	listen     string
+	hostname   string
+	provider   deliveryProvider
+	placeNames *placeNames
+}
+
+func parseServeArgs(cmd *flag.FlagSet, a []string) (serveArgs, error) {
+	var (
+		listenArg      string
+		hostnameArg    string
+		providerArg    string
+		placeNamesArg  bool
+		clientArgs     clientFlags
+		credentialArgs credentialFlags
+	)
+	clientArgs.register(cmd)
+	credentialArgs.register(cmd)
+	cmd.StringVar(&listenArg, "listen", ":8080", "Listen on `address`")
+	cmd.StringVar(&providerArg, "provider", bringProviderName, "Fetch delivery dates with `provider`: "+strings.Join(providerNames(), ", "))
+	cmd.StringVar(&hostnameArg, "hostname", "", "Use in UID")
+	cmd.BoolVar(&placeNamesArg, "place-names", false, "Look up post town and municipality from the Bring API")
+	if err := cmd.Parse(a); err != nil {
+		return serveArgs{}, err
+	}
+	if cmd.NArg() > 0 {
+		return serveArgs{}, fmt.Errorf("unexpected arguments: %s", strings.Join(cmd.Args(), " "))
+	}
+	opts, err := clientArgs.providerOptions(&credentialArgs, credentials{})
+	if err != nil {
+		return serveArgs{}, err
+	}
+	provider, err := newProvider(providerArg, opts)
+	if err != nil {
+		return serveArgs{}, err
+	}
+	var places *placeNames
+	if placeNamesArg {
+		places = newPlaceNames(opts.client, opts.baseURL, opts.credentials)
+	}
+	return serveArgs{
+		listen:     listenArg,
+		hostname:   hostnameArg,
+		provider:   provider,
+		placeNames: places,
+	}, nil
+}
+
+func serveCli(ctx context.Context, as []string) {
+	args, err := parseServeArgs(flag.NewFlagSet("serve", flag.ExitOnError), as)
+	if err != nil {
+		die(err)
+	}
+	handler := &calendarHandler{
+		provider:   args.provider,
+		placeNames: args.placeNames,
+		hostname:   resolveHostname(args.hostname),
+	}
+	if err = listenAndServe(ctx, args.listen, handler); err != nil {
+		die(err)
+	}
+}
+
+// listenAndServe serves handler on addr until ctx is done, then shuts
+// the server down gracefully.
+func listenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Print(err)
+		}
+	}()
+	log.Printf("Listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
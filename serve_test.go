@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func fixtureProvider(t *testing.T) deliveryProvider {
+	bs := readFixture("test/fixture.json", t)
+	return deliveryProviderFunc(func(context.Context, *postalCodeT) (*postenResponseT, *time.Time, error) {
+		return readData(now(), bytes.NewReader(bs))
+	})
+}
+
+func serveFixture(t *testing.T, provider deliveryProvider) *httptest.Server {
+	server := httptest.NewServer(&calendarHandler{provider: provider, hostname: "test"})
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServeCalendar(t *testing.T) {
+	server := serveFixture(t, fixtureProvider(t))
+	resp, err := http.Get(server.URL + "/6666.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+		t.Fatalf("Unexpected Content-Type: %s", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := string(readFixture("test/fixture.ics", t))
+	if string(body) != expected {
+		t.Fatalf("\n%s\n!=\n%s", body, expected)
+	}
+}
+
+func TestServeNotFound(t *testing.T) {
+	server := serveFixture(t, fixtureProvider(t))
+	for _, path := range []string{"/", "/6666", "/99999.ics", "/a/6666.ics", "/abcd.ics"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: expected %d, got %d", path, http.StatusNotFound, resp.StatusCode)
+		}
+	}
+}
+
+func TestServeMethodNotAllowed(t *testing.T) {
+	server := serveFixture(t, fixtureProvider(t))
+	resp, err := http.Post(server.URL+"/6666.ics", "text/plain", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected %d, got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
+
+func TestServeFetchError(t *testing.T) {
+	server := serveFixture(t, deliveryProviderFunc(func(context.Context, *postalCodeT) (*postenResponseT, *time.Time, error) {
+		return nil, nil, errors.New("backend down")
+	}))
+	resp, err := http.Get(server.URL + "/6666.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Expected %d, got %d", http.StatusBadGateway, resp.StatusCode)
+	}
+}
+
+func TestServeNoDeliveryDays(t *testing.T) {
+	server := serveFixture(t, deliveryProviderFunc(func(context.Context, *postalCodeT) (*postenResponseT, *time.Time, error) {
+		return &postenResponseT{}, now(), nil
+	}))
+	resp, err := http.Get(server.URL + "/6666.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestParseServeArgs(t *testing.T) {
+	got, err := parseServeArgs(commandLine(), []string{"--listen=127.0.0.1:0", "--hostname=test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.listen != "127.0.0.1:0" || got.hostname != "test" {
+		t.Fatalf("Unexpected %+v", got)
+	}
+	if _, err = parseServeArgs(commandLine(), []string{"extra"}); err == nil {
+		t.Fatal("Expected error")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/taasan/postgang/ical"
+)
+
+// eventState is what was last published for an event.
+type eventState struct {
+	Date string `json:"date"`
+	// Hash is the hash of the event content, without revision.
+	Hash         string    `json:"hash"`
+	Sequence     int       `json:"sequence"`
+	LastModified time.Time `json:"last_modified"`
+	Cancelled    bool      `json:"cancelled,omitempty"`
+}
+
+// scheduleState is the state file, keeping the events published by
+// previous runs by UID.
+type scheduleState struct {
+	Events map[string]*eventState `json:"events"`
+}
+
+func readScheduleState(path string) (*scheduleState, error) {
+	state := &scheduleState{Events: map[string]*eventState{}}
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(bs, state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if state.Events == nil {
+		state.Events = map[string]*eventState{}
+	}
+	return state, nil
+}
+
+func (state *scheduleState) write(path string) error {
+	bs, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(bs, '\n'))
+}
+
+// eventHash returns a hash of the event as it is printed, with a fixed
+// DTSTAMP. Pass events made by scheduleOf, so only schedule changes
+// change the hash.
+func eventHash(event *ical.VEvent) string {
+	sum := sha256.Sum256([]byte(ical.Calendar(ical.NewVCalendar("", &time.Time{}, event)).String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// revise updates the entry for an event published at now, bumping
+// SEQUENCE and setting LAST-MODIFIED when it changed.
+func (state *scheduleState) revise(uid, date, hash string, cancelled bool, now time.Time) {
+	entry, ok := state.Events[uid]
+	switch {
+	case !ok:
+		state.Events[uid] = &eventState{Date: date, Hash: hash, LastModified: now, Cancelled: cancelled}
+	case entry.Hash != hash || entry.Cancelled != cancelled:
+		entry.Hash, entry.Cancelled = hash, cancelled
+		entry.Sequence++
+		entry.LastModified = now
+	}
+}
+
+// scheduleOf returns cal without its place. A failed place name lookup
+// is no reason to revise the events.
+func scheduleOf(cal *calendarT) *calendarT {
+	schedule := *cal
+	schedule.place = nil
+	return &schedule
+}
+
+// track compares the calendars with the state, updating it. Dates
+// gone from a calendar are added to its cancelled dates until they are
+// in the past, then forgotten. The calendars get their revisions from
+// the state.
+func (state *scheduleState) track(cals []*calendarT) error {
+	withCode := len(cals) > 1
+	for _, cal := range cals {
+		now := cal.now.UTC().Truncate(time.Second)
+		today := cal.now.In(timezone).Format(time.DateOnly)
+		// The hashes are of events without revision.
+		cal.revisions, cal.cancelled = nil, nil
+		schedule := scheduleOf(cal)
+		current := map[string]bool{}
+		for _, date := range cal.dates {
+			uid := eventUID(date, cal, withCode)
+			current[uid] = true
+			state.revise(uid, date.time.Format(time.DateOnly), eventHash(toVEvent(date, schedule, withCode)), false, now)
+		}
+		var cancelled []string
+		for uid, entry := range state.Events {
+			if current[uid] || !cal.ownsUID(uid, withCode) {
+				continue
+			}
+			if entry.Date < today {
+				delete(state.Events, uid)
+				continue
+			}
+			cancelled = append(cancelled, uid)
+		}
+		sort.Strings(cancelled)
+		for _, uid := range cancelled {
+			entry := state.Events[uid]
+			t, err := time.Parse(time.DateOnly, entry.Date)
+			if err != nil {
+				return fmt.Errorf("state of %s: %w", uid, err)
+			}
+			date := &CivilTime{time: &t}
+			state.revise(uid, entry.Date, eventHash(toVEvent(date, schedule, withCode)), true, now)
+			cal.cancelled = append(cal.cancelled, date)
+		}
+		cal.revisions = state.Events
+	}
+	return nil
+}
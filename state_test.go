@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trackFixture tracks cal with the state in path, returning the
+// printed calendar.
+func trackFixture(t *testing.T, path string, cal *calendarT) string {
+	state, err := readScheduleState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = state.track([]*calendarT{cal}); err != nil {
+		t.Fatal(err)
+	}
+	if err = state.write(path); err != nil {
+		t.Fatal(err)
+	}
+	return toVCalendar(cal).String()
+}
+
+// eventOf returns the printed VEVENT with uid.
+func eventOf(t *testing.T, ics, uid string) string {
+	start := strings.Index(ics, "UID:"+uid+"\r\n")
+	if start < 0 {
+		t.Fatalf("No event %s in\n%s", uid, ics)
+	}
+	end := strings.Index(ics[start:], "END:VEVENT")
+	return ics[start : start+end]
+}
+
+func expectEvent(t *testing.T, event string, expected ...string) {
+	for _, s := range expected {
+		if !strings.Contains(event, s+"\r\n") {
+			t.Fatalf("Expected %q in\n%s", s, event)
+		}
+	}
+}
+
+func TestTrackSchedule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	got := trackFixture(t, path, calendarTFixture())
+	first := "LAST-MODIFIED:20211228T000000Z"
+	expectEvent(t, eventOf(t, got, "postgang-20211229@test"), "SEQUENCE:0", first)
+	if strings.Contains(got, "STATUS:") {
+		t.Fatalf("Unexpected STATUS in\n%s", got)
+	}
+
+	cal := calendarTFixture()
+	cal.dates = append(cal.dates[:1], cal.dates[2:]...)
+	later := cal.now.Add(90 * time.Minute)
+	cal.now = &later
+	got = trackFixture(t, path, cal)
+	expectEvent(t, eventOf(t, got, "postgang-20211229@test"), "SEQUENCE:1", "LAST-MODIFIED:20211228T013000Z", "STATUS:CANCELLED")
+	expectEvent(t, eventOf(t, got, "postgang-20211230@test"), "SEQUENCE:0", first)
+
+	// Unchanged events keep their revision.
+	if again := trackFixture(t, path, cal); again != got {
+		t.Fatalf("\n%s\n!=\n%s", again, got)
+	}
+
+	cal = calendarTFixture()
+	cal.language = languages["en"]
+	got = trackFixture(t, path, cal)
+	expectEvent(t, eventOf(t, got, "postgang-20211229@test"), "SEQUENCE:2")
+	expectEvent(t, eventOf(t, got, "postgang-20211230@test"), "SEQUENCE:1")
+	if strings.Contains(got, "STATUS:CANCELLED") {
+		t.Fatalf("Restored date still cancelled\n%s", got)
+	}
+}
+
+func TestTrackScheduleIgnoresPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	cal := calendarTFixture()
+	cal.place = &placeT{City: "SKOGEN", Municipality: "Bygda"}
+	trackFixture(t, path, cal)
+	// The place name lookup failed.
+	got := trackFixture(t, path, calendarTFixture())
+	expectEvent(t, eventOf(t, got, "postgang-20211229@test"), "SEQUENCE:0")
+}
+
+func TestTrackScheduleForgetsPastDates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	trackFixture(t, path, calendarTFixture())
+	cal := calendarTFixture()
+	cal.dates = cal.dates[2:]
+	cal.now = cal.dates[0].time
+	got := trackFixture(t, path, cal)
+	if strings.Contains(got, "STATUS:CANCELLED") {
+		t.Fatalf("Past dates cancelled\n%s", got)
+	}
+	state, err := readScheduleState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Events) != len(cal.dates) {
+		t.Fatalf("Expected %d events in state, got %d", len(cal.dates), len(state.Events))
+	}
+}
+
+func TestRunJobState(t *testing.T) {
+	dir := t.TempDir()
+	output, state := filepath.Join(dir, "6666.ics"), filepath.Join(dir, "state.json")
+	args, err := parseArgs(commandLine(), []string{
+		"--code=6666", "--provider=static", "--hostname=test", "--output", output, "--state", state,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = args.runJob(context.Background(), args.jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "SEQUENCE:0\r\n") {
+		t.Fatalf("No SEQUENCE in\n%s", bs)
+	}
+	if _, err = os.Stat(state); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const templateFields = ".Code, .Place, .Weekday, .Day, .Month, .MonthName, .Date and .DaysUntil"
+
+// eventData is what summary and description templates are executed
+// with.
+type eventData struct {
+	Code string
+	// Place is the post town, empty unless place names are looked up.
+	Place string
+	// Weekday is the name of the day in the calendar language.
+	Weekday string
+	Day     int
+	Month   int
+	// MonthName is the name of the month in the calendar language.
+	MonthName string
+	// Date is the ISO 8601 date, like 2021-12-28.
+	Date string
+	// DaysUntil is the number of days from today, 0 for today.
+	DaysUntil int
+}
+
+func (cal *calendarT) eventData(date *CivilTime) *eventData {
+	y, m, d := cal.now.In(timezone).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	day := time.Date(date.time.Year(), date.time.Month(), date.time.Day(), 0, 0, 0, 0, time.UTC)
+	data := &eventData{
+		Code:      cal.code.code,
+		Weekday:   cal.lang().weekdayNames[date.time.Weekday()],
+		Day:       date.time.Day(),
+		Month:     int(date.time.Month()),
+		MonthName: cal.lang().monthNames[date.time.Month()],
+		Date:      date.time.Format(time.DateOnly),
+		DaysUntil: int(day.Sub(today).Hours() / 24),
+	}
+	if cal.place != nil {
+		data.Place = cal.place.String()
+	}
+	return data
+}
+
+// parseEventTemplate parses text, checking that it only uses the
+// fields of eventData.
+func parseEventTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err == nil {
+		err = tmpl.Execute(io.Discard, &eventData{
+			Code: "6666", Weekday: "tirsdag", Day: 28, Month: 12, MonthName: "desember", Date: "2021-12-28",
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template, it can use %s: %w", name, templateFields, err)
+	}
+	return tmpl, nil
+}
+
+// renderTemplate returns tmpl executed with data, or fallback when
+// tmpl is nil or fails.
+func renderTemplate(tmpl *template.Template, data *eventData, fallback string) string {
+	if tmpl == nil {
+		return fallback
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		log.Printf("%s %s: %s", data.Code, data.Date, err)
+		return fallback
+	}
+	return sb.String()
+}
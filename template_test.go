@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEventData(t *testing.T) {
+	cal := calendarTFixture()
+	cal.place = &placeT{City: "SKOGEN", Municipality: "Bygda"}
+	got := cal.eventData(cal.dates[2])
+	expected := &eventData{
+		Code: "6666", Place: "SKOGEN, Bygda", Weekday: "torsdag", Day: 30, Month: 12, MonthName: "desember",
+		Date: "2021-12-30", DaysUntil: 2,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("%+v != %+v", got, expected)
+	}
+	cal.language = languages["en"]
+	if got = cal.eventData(cal.dates[2]); got.Weekday != "Thursday" || got.MonthName != "December" {
+		t.Fatalf("Unexpected names %+v", got)
+	}
+}
+
+func TestEventTemplates(t *testing.T) {
+	cal := calendarTFixture()
+	var err error
+	if cal.summaryTemplate, err = parseEventTemplate("summary", "📬 {{.Weekday}} {{.Day}}.{{.Month}}"); err != nil {
+		t.Fatal(err)
+	}
+	description := "{{if eq .DaysUntil 0}}I dag{{else}}Om {{.DaysUntil}} dager{{end}} ({{.Date}})"
+	if cal.descriptionTemplate, err = parseEventTemplate("description", description); err != nil {
+		t.Fatal(err)
+	}
+	got := toVCalendar(cal).String()
+	for _, expected := range []string{
+		"SUMMARY:📬 tirsdag 28.12\r\n",
+		"DESCRIPTION:I dag (2021-12-28)\r\n",
+		"SUMMARY:📬 onsdag 29.12\r\n",
+		"DESCRIPTION:Om 1 dager (2021-12-29)\r\n",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("Expected %q in\n%s", expected, got)
+		}
+	}
+}
+
+func TestParseEventTemplateErrors(t *testing.T) {
+	for _, text := range []string{"{{.Code", "{{.Town}}", "{{.Day.Year}}"} {
+		if _, err := parseEventTemplate("summary", text); err == nil {
+			t.Fatalf("Expected error for %s", text)
+		}
+	}
+}
+
+func TestParseArgsTemplates(t *testing.T) {
+	args, err := parseArgs(commandLine(), []string{"--code=6666", "--provider=static", "--summary-template", "{{.Code}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.jobs[0].summaryTemplate == nil || args.jobs[0].descriptionTemplate != nil {
+		t.Fatalf("Unexpected templates %+v", args.jobs[0])
+	}
+	for _, a := range [][]string{
+		{"--summary-template", "{{.Town}}"},
+		{"--description-template", "{{"},
+		{"--summary-template", "{{.Code}}", "--summary", "%s %s %d"},
+	} {
+		if _, err = parseArgs(commandLine(), append([]string{"--code=6666", "--provider=static"}, a...)); err == nil {
+			t.Fatalf("Expected error for %v", a)
+		}
+	}
+}
+
+func TestParseArgsTemplatePrecedence(t *testing.T) {
+	config := writeConfig(t, `{
+  "provider": "static",
+  "summary": "%s %s %d",
+  "jobs": [
+    {"code": "6666", "output": "/tmp/6666.ics", "summary_template": "{{.Day}}. {{.MonthName}}"},
+    {"code": "1234", "output": "/tmp/1234.ics"}
+  ]
+}`)
+	args, err := parseArgs(commandLine(), []string{"--config", config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := args.jobs[0]; job.summary != "" || job.summaryTemplate == nil {
+		t.Fatalf("Job template does not replace the summary: %+v", job)
+	}
+	if job := args.jobs[1]; job.summary == "" || job.summaryTemplate != nil {
+		t.Fatalf("Unexpected summary: %+v", job)
+	}
+	args, err = parseArgs(commandLine(), []string{"--config", config, "--code=6666", "--summary-template", "{{.Code}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := args.jobs[0]; job.summary != "" || job.summaryTemplate == nil {
+		t.Fatalf("Flag does not replace the summary: %+v", job)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// windowT is the time of day mail is usually delivered, as offsets
+// from midnight.
+type windowT struct {
+	start time.Duration
+	end   time.Duration
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseWindow parses a delivery window like 10:00-14:00.
+func parseWindow(value string) (*windowT, error) {
+	from, to, found := strings.Cut(value, "-")
+	if !found {
+		return nil, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", value)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return nil, err
+	}
+	if end <= start {
+		return nil, fmt.Errorf("invalid window %q, must end after it starts", value)
+	}
+	return &windowT{start: start, end: end}, nil
+}
+
+// on returns the start and end of the window on the day of date, in
+// loc.
+func (w *windowT) on(date *time.Time, loc *time.Location) (time.Time, time.Time) {
+	at := func(offset time.Duration) time.Time {
+		return time.Date(date.Year(), date.Month(), date.Day(),
+			int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, loc)
+	}
+	return at(w.start), at(w.end)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	got, err := parseWindow("10:00-14:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.start != 10*time.Hour || got.end != 14*time.Hour+30*time.Minute {
+		t.Fatalf("Unexpected window %+v", got)
+	}
+	for _, value := range []string{"10:00", "14:00-10:00", "10:00-10:00", "10-14", "10:00-25:00"} {
+		if _, err = parseWindow(value); err == nil {
+			t.Fatalf("%q: expected error", value)
+		}
+	}
+}
+
+func TestCalendarWindow(t *testing.T) {
+	cal := calendarTFixture()
+	cal.window, _ = parseWindow("10:00-14:00")
+	cal.alarms = []time.Duration{7*time.Hour + 30*time.Minute}
+	got := toVCalendar(cal).String()
+	for _, expected := range []string{
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Oslo\r\n",
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\r\n",
+		"DTSTART;TZID=Europe/Oslo:20211228T100000\r\nDTEND;TZID=Europe/Oslo:20211228T140000\r\n",
+		"TRIGGER:-PT2H30M\r\n",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("Expected %q in\n%s", expected, got)
+		}
+	}
+	if strings.Contains(got, "VALUE=DATE") {
+		t.Fatalf("Unexpected all-day event in\n%s", got)
+	}
+}
+
+func TestParseArgsWindow(t *testing.T) {
+	got, err := parseArgs(commandLine(), []string{"--code=6666", "--window", "10:00-14:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := got.jobs[0].window; w == nil || w.start != 10*time.Hour || w.end != 14*time.Hour {
+		t.Fatalf("Unexpected window %+v", w)
+	}
+	if _, err = parseArgs(commandLine(), []string{"--code=6666", "--window", "14:00-10:00"}); err == nil {
+		t.Fatal("Expected error")
+	}
+}